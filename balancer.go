@@ -0,0 +1,314 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultEjectionTime is used for the zero value of Balancer.EjectionTime.
+const defaultEjectionTime = 30 * time.Second
+
+// A BalancePolicy chooses among a service's replicas.
+type BalancePolicy uint8
+
+const (
+	BalanceRoundRobin    BalancePolicy = iota // take turns
+	BalanceLeastRequests                      // fewest in-flight requests
+	BalancePowerOfTwo                         // fewer in-flight requests of two random replicas
+)
+
+// A Balancer is a Doer that spreads requests across a service's replicas. It
+// watches a Resolver to discover the replicas' base URLs, chooses one for each
+// request using its BalancePolicy, and then sends the request through the
+// underlying Doer. Since the Resolver pushes changes to the Balancer, clients
+// using a Balancer don't need to be rebuilt when replicas come and go.
+//
+// Balancers start watching their Resolver when they send their first request,
+// and that request waits until the Resolver reports the initial replicas (or
+// the request's context is done). Call Close to stop watching.
+//
+// Balancers rewrite each request's URL: they replace the scheme, user
+// information, and host with the chosen replica's, and they prefix the path
+// with the replica's path (if any). Clients using a Balancer should use a base
+// URL without a path, like "http://ping".
+//
+// Balancers temporarily eject replicas that fail with connection errors or
+// respond with CodeUnavailable (or HTTP 503). Ejected replicas don't receive
+// requests until their ejection time elapses, unless every replica has been
+// ejected.
+//
+// A Balancer's fields must not be modified after its first use, and
+// Balancers must not be copied after first use. They're safe to use
+// concurrently.
+type Balancer struct {
+	// Resolver discovers replicas. It's required.
+	Resolver Resolver
+	// Doer sends requests. If nil, Balancers use http.DefaultClient.
+	Doer Doer
+	// Policy chooses among healthy replicas. The default is
+	// BalanceRoundRobin.
+	Policy BalancePolicy
+	// EjectionTime is how long unhealthy replicas are ejected. If zero,
+	// Balancers use 30 seconds.
+	EjectionTime time.Duration
+
+	next uint64 // for round-robin
+
+	start  sync.Once
+	ready  chan struct{} // closed after the Resolver's first update
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	err       error // from the Resolver's most recent update
+}
+
+var _ Doer = (*Balancer)(nil)
+
+// Do chooses a replica and sends the request to it.
+func (b *Balancer) Do(request *http.Request) (*http.Response, error) {
+	ep, err := b.pick(request.Context())
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&ep.outstanding, 1)
+	response, err := b.doer().Do(ep.rewrite(request))
+	if err != nil {
+		atomic.AddInt64(&ep.outstanding, -1)
+		// Canceled and timed-out requests don't say anything about the replica.
+		if request.Context().Err() == nil {
+			b.eject(ep)
+		}
+		return nil, err
+	}
+	if isUnavailable(response.StatusCode, response.Header) {
+		b.eject(ep)
+	}
+	response.Body = &balancedBody{
+		ReadCloser: response.Body,
+		balancer:   b,
+		endpoint:   ep,
+		response:   response,
+	}
+	return response, nil
+}
+
+func (b *Balancer) doer() Doer {
+	if b.Doer == nil {
+		return http.DefaultClient
+	}
+	return b.Doer
+}
+
+// pick chooses a replica, resolving replicas if necessary.
+func (b *Balancer) pick(ctx context.Context) (*endpoint, error) {
+	endpoints, err := b.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	healthy := make([]*endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.available(now) {
+			healthy = append(healthy, ep)
+		}
+	}
+	if len(healthy) == 0 {
+		// If everything is ejected, ejection isn't helping.
+		healthy = endpoints
+	}
+	switch b.Policy {
+	case BalanceLeastRequests:
+		// Start at a rotating offset, so ties don't always go to the same
+		// replica.
+		offset := int(atomic.AddUint64(&b.next, 1) % uint64(len(healthy)))
+		best := healthy[offset]
+		for i := 1; i < len(healthy); i++ {
+			ep := healthy[(offset+i)%len(healthy)]
+			if atomic.LoadInt64(&ep.outstanding) < atomic.LoadInt64(&best.outstanding) {
+				best = ep
+			}
+		}
+		return best, nil
+	case BalancePowerOfTwo:
+		if len(healthy) == 1 {
+			return healthy[0], nil
+		}
+		i := rand.Intn(len(healthy))
+		j := rand.Intn(len(healthy) - 1)
+		if j >= i {
+			j++
+		}
+		first, second := healthy[i], healthy[j]
+		if atomic.LoadInt64(&second.outstanding) < atomic.LoadInt64(&first.outstanding) {
+			return second, nil
+		}
+		return first, nil
+	default:
+		n := atomic.AddUint64(&b.next, 1) - 1
+		return healthy[n%uint64(len(healthy))], nil
+	}
+}
+
+// current returns the replicas, waiting for the Resolver's first update if
+// necessary.
+func (b *Balancer) current(ctx context.Context) ([]*endpoint, error) {
+	b.start.Do(b.watch)
+	select {
+	case <-b.ready:
+	case <-ctx.Done():
+		return nil, errorf(CodeUnavailable, "no endpoints resolved: %w", ctx.Err())
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.endpoints) == 0 {
+		if b.err != nil {
+			return nil, errorf(CodeUnavailable, "can't resolve endpoints: %w", b.err)
+		}
+		return nil, errorf(CodeUnavailable, "no endpoints available")
+	}
+	return b.endpoints, nil
+}
+
+// watch starts watching the Resolver in the background.
+func (b *Balancer) watch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.ready = make(chan struct{})
+	b.cancel = cancel
+	var once sync.Once
+	go b.Resolver.Watch(ctx, func(urls []string, err error) {
+		b.update(urls, err)
+		once.Do(func() { close(b.ready) })
+	})
+}
+
+// Close stops watching the Resolver. Requests sent after Close use the most
+// recently resolved replicas.
+func (b *Balancer) Close() error {
+	b.start.Do(func() {
+		// Never watch the Resolver, and don't make requests wait for it.
+		b.ready = make(chan struct{})
+		close(b.ready)
+	})
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return nil
+}
+
+// update applies a change from the Resolver, keeping the state of replicas
+// that are still present. If resolution failed, the Balancer keeps its
+// current replicas.
+func (b *Balancer) update(urls []string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+	if err != nil {
+		return
+	}
+	existing := make(map[string]*endpoint, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		existing[ep.base.String()] = ep
+	}
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, raw := range urls {
+		base, err := url.Parse(raw)
+		if err == nil && (base.Scheme == "" || base.Host == "") {
+			err = errors.New("must be an absolute URL")
+		}
+		if err != nil {
+			b.err = fmt.Errorf("invalid endpoint %q: %w", raw, err)
+			return
+		}
+		if ep, ok := existing[base.String()]; ok {
+			endpoints = append(endpoints, ep)
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{base: base})
+	}
+	b.endpoints = endpoints
+}
+
+func (b *Balancer) eject(ep *endpoint) {
+	d := b.EjectionTime
+	if d <= 0 {
+		d = defaultEjectionTime
+	}
+	atomic.StoreInt64(&ep.ejectedUntil, time.Now().Add(d).UnixNano())
+}
+
+// endpoint is a single replica.
+type endpoint struct {
+	base         *url.URL
+	outstanding  int64 // in-flight requests
+	ejectedUntil int64 // Unix nanoseconds
+}
+
+func (e *endpoint) available(now time.Time) bool {
+	return now.UnixNano() >= atomic.LoadInt64(&e.ejectedUntil)
+}
+
+// rewrite points a copy of the request at the endpoint.
+func (e *endpoint) rewrite(request *http.Request) *http.Request {
+	rewritten := request.Clone(request.Context())
+	u := *request.URL
+	u.Scheme = e.base.Scheme
+	u.User = e.base.User
+	u.Host = e.base.Host
+	u.Path = strings.TrimSuffix(e.base.Path, "/") + u.Path
+	u.RawPath = ""
+	rewritten.URL = &u
+	rewritten.Host = "" // use the URL's host
+	return rewritten
+}
+
+// balancedBody tracks in-flight requests and checks the response trailers for
+// CodeUnavailable once the body is exhausted or closed.
+type balancedBody struct {
+	io.ReadCloser
+
+	balancer *Balancer
+	endpoint *endpoint
+	response *http.Response
+	once     sync.Once
+}
+
+func (b *balancedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *balancedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *balancedBody) finish() {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.endpoint.outstanding, -1)
+		// Trailers are only populated once the body has been read to EOF.
+		if b.response.Trailer.Get("Grpc-Status") == strconv.Itoa(int(CodeUnavailable)) {
+			b.balancer.eject(b.endpoint)
+		}
+	})
+}
+
+// isUnavailable checks response headers for signs of an unhealthy replica.
+func isUnavailable(status int, header http.Header) bool {
+	return status == http.StatusServiceUnavailable ||
+		header.Get("Grpc-Status") == strconv.Itoa(int(CodeUnavailable))
+}
@@ -0,0 +1,289 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+// fakeResolver pushes whatever updates the test sends. Sending blocks until
+// the Balancer has applied the update.
+type fakeResolver struct {
+	updates chan fakeUpdate
+}
+
+type fakeUpdate struct {
+	urls []string
+	err  error
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{updates: make(chan fakeUpdate)}
+}
+
+func (r *fakeResolver) Watch(ctx context.Context, update func([]string, error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-r.updates:
+			update(u.urls, u.err)
+		}
+	}
+}
+
+func (r *fakeResolver) set(urls ...string) {
+	r.updates <- fakeUpdate{urls: urls}
+	r.updates <- fakeUpdate{urls: urls} // wait for the first update to apply
+}
+
+func (r *fakeResolver) fail(err error) {
+	r.updates <- fakeUpdate{err: err}
+}
+
+// newNamedServer starts a server that responds with its name and the request
+// path.
+func newNamedServer(t testing.TB, name string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name+" "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t testing.TB, doer Doer, path string) (string, error) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, "http://backend"+path, nil)
+	assert.Nil(t, err, "create request")
+	response, err := doer.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.Nil(t, err, "read body")
+	return string(body), nil
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	a, b := newNamedServer(t, "a"), newNamedServer(t, "b")
+	balancer := &Balancer{Resolver: StaticResolver{a.URL, b.URL + "/prefix/"}}
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		body, err := get(t, balancer, "/foo")
+		assert.Nil(t, err, "get")
+		counts[body]++
+	}
+	assert.Equal(t, counts, map[string]int{"a /foo": 2, "b /prefix/foo": 2}, "distribution")
+}
+
+func TestBalancerOutstanding(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := newNamedServer(t, "fast")
+
+	for _, policy := range []BalancePolicy{BalanceLeastRequests, BalancePowerOfTwo} {
+		balancer := &Balancer{
+			Resolver: StaticResolver{slow.URL, fast.URL},
+			Policy:   policy,
+		}
+		// Open requests until one is stuck on the slow server.
+		for {
+			request, err := http.NewRequest(http.MethodGet, "http://backend/", nil)
+			assert.Nil(t, err, "create request")
+			response, err := balancer.Do(request)
+			assert.Nil(t, err, "do")
+			if response.Request.URL.Host == slow.Listener.Addr().String() {
+				defer response.Body.Close()
+				break
+			}
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		for i := 0; i < 4; i++ {
+			body, err := get(t, balancer, "/")
+			assert.Nil(t, err, "get")
+			assert.Equal(t, body, "fast /", "avoids busy replica")
+		}
+	}
+}
+
+func TestBalancerEjection(t *testing.T) {
+	healthy := newNamedServer(t, "healthy")
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Grpc-Status", "14")
+	}))
+	defer unavailable.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	balancer := &Balancer{Resolver: StaticResolver{unavailable.URL, closed.URL, healthy.URL}}
+	// Within a few requests, round-robin reaches and ejects both unhealthy
+	// replicas.
+	var opErr *net.OpError
+	var connErrs int
+	for i := 0; i < 4; i++ {
+		if _, err := get(t, balancer, "/"); err != nil {
+			assert.True(t, errors.As(err, &opErr), "closed replica")
+			connErrs++
+		}
+	}
+	assert.Equal(t, connErrs, 1, "connection errors")
+	for i := 0; i < 4; i++ {
+		body, err := get(t, balancer, "/")
+		assert.Nil(t, err, "get")
+		assert.Equal(t, body, "healthy /", "ejected replicas skipped")
+	}
+
+	// If everything is ejected, balancers keep trying.
+	balancer = &Balancer{Resolver: StaticResolver{closed.URL}, EjectionTime: time.Hour}
+	for i := 0; i < 2; i++ {
+		_, err := get(t, balancer, "/")
+		assert.True(t, errors.As(err, &opErr), "closed replica")
+	}
+}
+
+func TestBalancerResolverUpdates(t *testing.T) {
+	a, b := newNamedServer(t, "a"), newNamedServer(t, "b")
+	resolver := newFakeResolver()
+	balancer := &Balancer{Resolver: resolver}
+	defer balancer.Close()
+
+	// Requests wait for the first update.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://backend/", nil)
+	assert.Nil(t, err, "create request")
+	_, err = balancer.Do(request)
+	assert.Equal(t, CodeOf(err), CodeUnavailable, "no update yet")
+
+	resolver.fail(errors.New("oh no"))
+	_, err = get(t, balancer, "/")
+	assert.Equal(t, CodeOf(err), CodeUnavailable, "resolution failed")
+	assert.Match(t, err.Error(), "oh no", "error message")
+
+	resolver.set(a.URL)
+	body, err := get(t, balancer, "/")
+	assert.Nil(t, err, "get")
+	assert.Equal(t, body, "a /", "resolved")
+
+	// Failures keep the previous replicas.
+	resolver.fail(errors.New("oh no"))
+	body, err = get(t, balancer, "/")
+	assert.Nil(t, err, "get after failure")
+	assert.Equal(t, body, "a /", "kept replicas")
+
+	resolver.set(b.URL)
+	body, err = get(t, balancer, "/")
+	assert.Nil(t, err, "get")
+	assert.Equal(t, body, "b /", "updated")
+
+	resolver.set("not a url")
+	_, err = get(t, balancer, "/")
+	assert.Nil(t, err, "invalid update ignored")
+}
+
+func TestResolvers(t *testing.T) {
+	watch := func(t testing.TB, r Resolver) ([]string, error) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		type result struct {
+			urls []string
+			err  error
+		}
+		results := make(chan result, 1)
+		go r.Watch(ctx, func(urls []string, err error) {
+			select {
+			case results <- result{urls, err}:
+			default:
+			}
+		})
+		res := <-results
+		return res.urls, res.err
+	}
+
+	t.Run("static", func(t *testing.T) {
+		urls, err := watch(t, StaticResolver{"http://a", "http://b"})
+		assert.Nil(t, err, "watch")
+		assert.Equal(t, urls, []string{"http://a", "http://b"}, "urls")
+		_, err = watch(t, StaticResolver{})
+		assert.NotNil(t, err, "empty")
+	})
+	t.Run("dns", func(t *testing.T) {
+		urls, err := watch(t, &DNSResolver{Host: "localhost", Port: 8080, Scheme: "http"})
+		assert.Nil(t, err, "watch")
+		assert.True(t, len(urls) > 0, "found localhost")
+		for _, u := range urls {
+			assert.Match(t, u, `^http://.*:8080$`, "url")
+		}
+	})
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "endpoints.json")
+		resolver := &FileResolver{Path: path, Interval: time.Millisecond}
+		_, err := watch(t, resolver)
+		assert.NotNil(t, err, "missing file")
+
+		err = os.WriteFile(path, []byte(`{"endpoints": ["http://b", "http://a"]}`), 0600)
+		assert.Nil(t, err, "write file")
+		urls, err := watch(t, resolver)
+		assert.Nil(t, err, "watch")
+		assert.Equal(t, urls, []string{"http://a", "http://b"}, "urls")
+
+		resolver.Unmarshal = func(bs []byte, v interface{}) error {
+			return errors.New("not YAML")
+		}
+		_, err = watch(t, resolver)
+		assert.Match(t, err.Error(), "not YAML", "custom unmarshal")
+	})
+	t.Run("file_changes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "endpoints.json")
+		write := func(urls string) {
+			err := os.WriteFile(path, []byte(`{"endpoints": [`+urls+`]}`), 0600)
+			assert.Nil(t, err, "write file")
+		}
+		write(`"http://a"`)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		updates := make(chan []string)
+		go (&FileResolver{Path: path, Interval: time.Millisecond}).Watch(ctx, func(urls []string, err error) {
+			assert.Nil(t, err, "update error")
+			select {
+			case updates <- urls:
+			case <-ctx.Done():
+			}
+		})
+		assert.Equal(t, <-updates, []string{"http://a"}, "initial")
+		write(`"http://a", "http://b"`)
+		assert.Equal(t, <-updates, []string{"http://a", "http://b"}, "changed")
+	})
+}
+
+func TestSRVURLs(t *testing.T) {
+	urls, err := srvURLs([]*net.SRV{
+		{Target: "a.example.com.", Port: 8080, Priority: 10},
+		{Target: "b.example.com.", Port: 8081, Priority: 20},
+		{Target: "c.example.com.", Port: 8082, Priority: 10},
+	}, "")
+	assert.Nil(t, err, "convert records")
+	assert.Equal(t, urls, []string{"https://a.example.com:8080", "https://c.example.com:8082"}, "urls")
+	_, err = srvURLs(nil, "http")
+	assert.NotNil(t, err, "no records")
+}
@@ -0,0 +1,311 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for the zero values of CircuitBreaker's fields.
+const (
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerMinRequests  = 20
+	defaultBreakerWindow       = 10 * time.Second
+	defaultBreakerOpenTimeout  = 5 * time.Second
+	defaultBreakerProbes       = 1
+)
+
+// defaultBreakerFailureCodes are the codes that suggest an unhealthy server,
+// rather than a problem with the request.
+var defaultBreakerFailureCodes = []Code{
+	CodeUnknown,
+	CodeDeadlineExceeded,
+	CodeResourceExhausted,
+	CodeInternal,
+	CodeUnavailable,
+}
+
+// A CircuitState is the state of a circuit breaker.
+type CircuitState uint8
+
+const (
+	CircuitClosed   CircuitState = iota // calls flow normally
+	CircuitOpen                         // calls fail fast
+	CircuitHalfOpen                     // a few probe calls test the server
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", s)
+}
+
+// A CircuitBreaker stops clients from overwhelming an unhealthy server. It
+// tracks the failure rate of unary calls, either per method or per service.
+// Once the failure rate crosses a threshold, the circuit opens: calls fail
+// immediately with CodeUnavailable, without reaching the server. After a
+// timeout, the circuit becomes half-open and allows a few probe calls. If the
+// probes succeed, the circuit closes; if any fail, it opens again.
+//
+// Circuit breakers only track unary calls, and they only count errors that
+// suggest an unhealthy server (by default, CodeUnknown, CodeDeadlineExceeded,
+// CodeResourceExhausted, CodeInternal, and CodeUnavailable). They see each
+// call once, after any retries or hedging. Calls that fail before the client
+// sends anything, like calls whose deadline has already passed, say nothing
+// about the server, so they don't count at all; neither do calls canceled by
+// the caller or messages over the client's own size limits.
+//
+// Hooks.OnCircuitStateChange receives every state change. CircuitBreakers are
+// valid CallOptions. Because they're stateful, use the same CircuitBreaker for
+// all the clients that should share circuits. A CircuitBreaker's fields must
+// not be modified after its first use.
+type CircuitBreaker struct {
+	// PerService tracks each service as a whole, rather than each method
+	// separately.
+	PerService bool
+	// FailureRatio is the fraction of failed calls that opens the circuit. If
+	// zero, CircuitBreakers use 0.5.
+	FailureRatio float64
+	// MinRequests is the minimum number of calls in a window before the
+	// circuit can open. If zero, CircuitBreakers use 20.
+	MinRequests int
+	// Window is how long closed circuits accumulate calls before resetting
+	// their counts. If zero, CircuitBreakers use 10 seconds.
+	Window time.Duration
+	// OpenTimeout is how long circuits stay open before allowing probes. If
+	// zero, CircuitBreakers use 5 seconds.
+	OpenTimeout time.Duration
+	// Probes is the number of concurrent probe calls allowed while half-open,
+	// and the number of successes required to close the circuit. If zero,
+	// CircuitBreakers use 1.
+	Probes int
+	// FailureCodes overrides the list of error codes counted as failures.
+	FailureCodes []Code
+
+	now func() time.Time // for tests
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func (b *CircuitBreaker) applyToCall(cfg *callCfg) {
+	cfg.CircuitBreaker = b
+}
+
+// circuit is the state for a single method or service.
+type circuit struct {
+	state     CircuitState
+	since     time.Time // when the state or window began
+	requests  int
+	failures  int
+	probing   int    // in-flight probes
+	successes int    // successful probes
+	reason    string // why the circuit opened
+}
+
+// allow checks whether a call may proceed. If it returns a nil error, the
+// caller must report the call's outcome with done, passing along whether the
+// call is a probe.
+func (b *CircuitBreaker) allow(ctx context.Context, spec *Specification, hooks *Hooks) (bool, *Error) {
+	name := b.name(spec)
+	b.mu.Lock()
+	c := b.circuit(name)
+	now := b.clock()
+	var change func()
+	defer func() {
+		b.mu.Unlock()
+		if change != nil {
+			change()
+		}
+	}()
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.since) >= b.window() {
+			c.since, c.requests, c.failures = now, 0, 0
+		}
+		return false, nil
+	case CircuitOpen:
+		remaining := b.openTimeout() - now.Sub(c.since)
+		if remaining > 0 {
+			return false, errorf(
+				CodeUnavailable,
+				"circuit breaker for %s is open (%s): failing fast for another %v",
+				name, c.reason, remaining.Round(time.Millisecond),
+			)
+		}
+		change = b.transition(ctx, hooks, name, c, CircuitHalfOpen, now)
+	}
+	// Half-open.
+	if c.probing >= b.probes() {
+		return false, errorf(CodeUnavailable, "circuit breaker for %s is half-open: waiting for probe calls to finish", name)
+	}
+	c.probing++
+	return true, nil
+}
+
+// done records the outcome of a call that allow permitted, along with whether
+// the call sent any requests. Calls that finish after their circuit changes
+// state don't count.
+func (b *CircuitBreaker) done(ctx context.Context, spec *Specification, hooks *Hooks, probe bool, err *Error, sent bool) {
+	name := b.name(spec)
+	failed, ignored := b.classify(err, sent)
+	b.mu.Lock()
+	c := b.circuit(name)
+	now := b.clock()
+	var change func()
+	switch {
+	case ignored:
+		if probe && c.state == CircuitHalfOpen {
+			// Free the probe's slot without deciding anything.
+			c.probing--
+		}
+	case !probe && c.state == CircuitClosed:
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.minRequests() && float64(c.failures) >= b.failureRatio()*float64(c.requests) {
+			c.reason = fmt.Sprintf("%d of %d calls failed", c.failures, c.requests)
+			change = b.transition(ctx, hooks, name, c, CircuitOpen, now)
+		}
+	case probe && c.state == CircuitHalfOpen:
+		c.probing--
+		if failed {
+			c.reason = fmt.Sprintf("probe call failed with %v", err.Code())
+			change = b.transition(ctx, hooks, name, c, CircuitOpen, now)
+		} else if c.successes++; c.successes >= b.probes() {
+			change = b.transition(ctx, hooks, name, c, CircuitClosed, now)
+		}
+	}
+	b.mu.Unlock()
+	if change != nil {
+		change()
+	}
+}
+
+// transition changes the circuit's state and returns a function that calls
+// the hook. Callers must hold the lock, and they must call the returned
+// function after releasing it.
+func (b *CircuitBreaker) transition(ctx context.Context, hooks *Hooks, name string, c *circuit, to CircuitState, now time.Time) func() {
+	from := c.state
+	c.state, c.since = to, now
+	c.probing, c.successes = 0, 0
+	if to == CircuitClosed {
+		c.requests, c.failures = 0, 0
+	}
+	return func() {
+		hooks.onCircuitStateChange(ctx, name, from, to)
+	}
+}
+
+func (b *CircuitBreaker) name(spec *Specification) string {
+	if b.PerService {
+		return spec.Service
+	}
+	return spec.Method
+}
+
+// circuit returns the named circuit, creating it if necessary. Callers must
+// hold the lock.
+func (b *CircuitBreaker) circuit(name string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{since: b.clock()}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// classify reports whether a call's outcome counts as a failure, and whether
+// it should be ignored entirely. Only outcomes from the server or the network
+// say anything about the server's health.
+func (b *CircuitBreaker) classify(err *Error, sent bool) (failed, ignored bool) {
+	switch {
+	case err == nil:
+		return false, false
+	case !sent, err.Code() == CodeCanceled, errors.Is(err, errTooLarge):
+		return false, true
+	}
+	return b.isFailure(err.Code()), false
+}
+
+func (b *CircuitBreaker) isFailure(code Code) bool {
+	codes := b.FailureCodes
+	if len(codes) == 0 {
+		codes = defaultBreakerFailureCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withSentFlag returns a context that records whether any attempt of a call
+// hands a request to the Doer.
+func withSentFlag(ctx context.Context) (context.Context, *int32) {
+	sent := new(int32)
+	return context.WithValue(ctx, requestSentKey, sent), sent
+}
+
+// markSent notes that a call is about to hand a request to its Doer.
+func markSent(ctx context.Context) {
+	if sent, ok := ctx.Value(requestSentKey).(*int32); ok {
+		atomic.StoreInt32(sent, 1)
+	}
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+func (b *CircuitBreaker) failureRatio() float64 {
+	if b.FailureRatio <= 0 {
+		return defaultBreakerFailureRatio
+	}
+	return b.FailureRatio
+}
+
+func (b *CircuitBreaker) minRequests() int {
+	if b.MinRequests <= 0 {
+		return defaultBreakerMinRequests
+	}
+	return b.MinRequests
+}
+
+func (b *CircuitBreaker) window() time.Duration {
+	if b.Window <= 0 {
+		return defaultBreakerWindow
+	}
+	return b.Window
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return defaultBreakerOpenTimeout
+	}
+	return b.OpenTimeout
+}
+
+func (b *CircuitBreaker) probes() int {
+	if b.Probes <= 0 {
+		return defaultBreakerProbes
+	}
+	return b.Probes
+}
@@ -0,0 +1,181 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+type circuitChange struct {
+	Name     string
+	From, To CircuitState
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	ping := &Specification{Method: "acme.ping.v1.PingService.Ping", Service: "acme.ping.v1.PingService"}
+	sum := &Specification{Method: "acme.ping.v1.PingService.Sum", Service: "acme.ping.v1.PingService"}
+
+	newBreaker := func(perService bool) (*CircuitBreaker, *Hooks, *[]circuitChange, *time.Time) {
+		now := time.Unix(0, 0)
+		var changes []circuitChange
+		hooks := &Hooks{OnCircuitStateChange: func(_ context.Context, name string, from, to CircuitState) {
+			changes = append(changes, circuitChange{name, from, to})
+		}}
+		b := &CircuitBreaker{
+			PerService:  perService,
+			MinRequests: 4,
+			Window:      time.Minute,
+			OpenTimeout: time.Second,
+			now:         func() time.Time { return now },
+		}
+		return b, hooks, &changes, &now
+	}
+	call := func(t testing.TB, b *CircuitBreaker, hooks *Hooks, spec *Specification, code Code) *Error {
+		t.Helper()
+		probe, err := b.allow(ctx, spec, hooks)
+		if err != nil {
+			return err
+		}
+		b.done(ctx, spec, hooks, probe, wrap(code, errors.New("oh no")), true /* sent */)
+		return nil
+	}
+
+	t.Run("trip_and_recover", func(t *testing.T) {
+		b, hooks, changes, now := newBreaker(false)
+		assert.Nil(t, call(t, b, hooks, ping, CodeOK), "call")
+		assert.Nil(t, call(t, b, hooks, ping, CodeNotFound), "client errors don't count")
+		assert.Nil(t, call(t, b, hooks, ping, CodeUnavailable), "call")
+		assert.Zero(t, len(*changes), "below MinRequests")
+		assert.Nil(t, call(t, b, hooks, ping, CodeInternal), "call")
+		assert.Equal(t, len(*changes), 1, "opened")
+		assert.Equal(t, (*changes)[0], circuitChange{ping.Method, CircuitClosed, CircuitOpen}, "change")
+
+		err := call(t, b, hooks, ping, CodeOK)
+		assert.NotNil(t, err, "fail fast")
+		assert.Equal(t, err.Code(), CodeUnavailable, "code")
+		assert.Match(t, err.Error(), `circuit breaker for .*\.Ping is open \(2 of 4 calls failed\)`, "message")
+		assert.Nil(t, call(t, b, hooks, sum, CodeOK), "other methods unaffected")
+
+		*now = now.Add(time.Second)
+		probe, err := b.allow(ctx, ping, hooks)
+		assert.Nil(t, err, "probe allowed")
+		assert.True(t, probe, "probe")
+		assert.Equal(t, (*changes)[1], circuitChange{ping.Method, CircuitOpen, CircuitHalfOpen}, "change")
+		_, err = b.allow(ctx, ping, hooks)
+		assert.NotNil(t, err, "only one probe")
+		assert.Match(t, err.Error(), "half-open", "message")
+		b.done(ctx, ping, hooks, probe, nil, true)
+		assert.Equal(t, (*changes)[2], circuitChange{ping.Method, CircuitHalfOpen, CircuitClosed}, "change")
+		assert.Nil(t, call(t, b, hooks, ping, CodeOK), "closed")
+	})
+
+	t.Run("probe_failure", func(t *testing.T) {
+		b, hooks, changes, now := newBreaker(false)
+		for i := 0; i < 4; i++ {
+			call(t, b, hooks, ping, CodeUnavailable)
+		}
+		*now = now.Add(time.Second)
+		assert.Nil(t, call(t, b, hooks, ping, CodeDeadlineExceeded), "probe")
+		assert.Equal(t, len(*changes), 3, "changes")
+		assert.Equal(t, (*changes)[2], circuitChange{ping.Method, CircuitHalfOpen, CircuitOpen}, "reopened")
+		err := call(t, b, hooks, ping, CodeOK)
+		assert.NotNil(t, err, "fail fast")
+		assert.Match(t, err.Error(), "probe call failed with DeadlineExceeded", "message")
+	})
+
+	t.Run("stale_calls", func(t *testing.T) {
+		b, hooks, changes, now := newBreaker(false)
+		slow, err := b.allow(ctx, ping, hooks)
+		assert.Nil(t, err, "allow")
+		for i := 0; i < 4; i++ {
+			call(t, b, hooks, ping, CodeUnavailable)
+		}
+		*now = now.Add(time.Second)
+		probe, err := b.allow(ctx, ping, hooks)
+		assert.Nil(t, err, "probe allowed")
+		// A call that started before the circuit opened isn't a probe.
+		b.done(ctx, ping, hooks, slow, nil, true)
+		assert.Equal(t, len(*changes), 2, "no change")
+		b.done(ctx, ping, hooks, probe, nil, true)
+		assert.Equal(t, (*changes)[2].To, CircuitClosed, "closed")
+	})
+
+	t.Run("window", func(t *testing.T) {
+		b, hooks, changes, now := newBreaker(false)
+		for i := 0; i < 3; i++ {
+			call(t, b, hooks, ping, CodeUnavailable)
+		}
+		*now = now.Add(time.Minute)
+		call(t, b, hooks, ping, CodeUnavailable)
+		assert.Zero(t, len(*changes), "counts reset")
+	})
+
+	t.Run("per_service", func(t *testing.T) {
+		b, hooks, changes, _ := newBreaker(true)
+		call(t, b, hooks, ping, CodeUnavailable)
+		call(t, b, hooks, ping, CodeUnavailable)
+		call(t, b, hooks, sum, CodeUnavailable)
+		call(t, b, hooks, sum, CodeUnavailable)
+		assert.Equal(t, len(*changes), 1, "opened")
+		assert.Equal(t, (*changes)[0].Name, ping.Service, "circuit name")
+		assert.NotNil(t, call(t, b, hooks, sum, CodeOK), "whole service fails fast")
+	})
+
+	t.Run("ignored_outcomes", func(t *testing.T) {
+		b, hooks, changes, now := newBreaker(false)
+		for i := 0; i < 4; i++ {
+			probe, err := b.allow(ctx, ping, hooks)
+			assert.Nil(t, err, "allow")
+			// For example, the caller's deadline passed before the call started.
+			b.done(ctx, ping, hooks, probe, errorf(CodeDeadlineExceeded, "no time"), false /* sent */)
+		}
+		assert.Zero(t, len(*changes), "errors without requests ignored")
+
+		for i := 0; i < 4; i++ {
+			call(t, b, hooks, ping, CodeUnavailable)
+		}
+		*now = now.Add(time.Second)
+		probe, err := b.allow(ctx, ping, hooks)
+		assert.Nil(t, err, "probe allowed")
+		b.done(ctx, ping, hooks, probe, errorf(CodeCanceled, "canceled"), true)
+		assert.Equal(t, len(*changes), 2, "canceled probe doesn't close or reopen")
+		assert.Equal(t, (*changes)[1].To, CircuitHalfOpen, "still half-open")
+		probe, err = b.allow(ctx, ping, hooks)
+		assert.Nil(t, err, "canceled probe freed its slot")
+		b.done(ctx, ping, hooks, probe, nil, true)
+		assert.Equal(t, (*changes)[2].To, CircuitClosed, "closed")
+	})
+
+	t.Run("too_large", func(t *testing.T) {
+		b, hooks, changes, _ := newBreaker(false)
+		for i := 0; i < 4; i++ {
+			probe, err := b.allow(ctx, ping, hooks)
+			assert.Nil(t, err, "allow")
+			// The server responded, but the response exceeded ReadMaxBytes.
+			tooLarge := wrap(CodeResourceExhausted, fmt.Errorf("%w: got 2 bytes, max is 1", errTooLarge))
+			b.done(ctx, ping, hooks, probe, tooLarge, true)
+		}
+		assert.Zero(t, len(*changes), "client-side limits ignored")
+	})
+
+	t.Run("failure_codes", func(t *testing.T) {
+		b, hooks, changes, _ := newBreaker(false)
+		b.FailureCodes = []Code{CodeNotFound}
+		for i := 0; i < 4; i++ {
+			call(t, b, hooks, ping, CodeUnavailable)
+		}
+		assert.Zero(t, len(*changes), "unavailable ignored")
+	})
+}
+
+func TestCircuitStateString(t *testing.T) {
+	assert.Equal(t, CircuitClosed.String(), "closed", "closed")
+	assert.Equal(t, CircuitOpen.String(), "open", "open")
+	assert.Equal(t, CircuitHalfOpen.String(), "half-open", "half-open")
+	assert.Equal(t, CircuitState(42).String(), "CircuitState(42)", "unknown")
+}
@@ -3,17 +3,21 @@ package rerpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/protobuf/proto"
 
 	statuspb "github.com/rerpc/rerpc/internal/status/v1"
+	"github.com/rerpc/rerpc/internal/twirp"
 )
 
 // Doer is the transport-level interface reRPC expects HTTP clients to
@@ -23,10 +27,32 @@ type Doer interface {
 }
 
 type callCfg struct {
-	EnableGzipRequest bool
-	MaxResponseBytes  int
-	Interceptor       Interceptor
-	Hooks             *Hooks
+	RequestCompression    string
+	Compressors           *compressors
+	CompressMinBytes      int
+	MaxResponseBytes      int
+	MaxDecompressedBytes  int
+	MaxRequestBytes       int
+	TwirpContentType      string
+	Idempotent            bool
+	RetryPolicy           *RetryPolicy
+	MethodRetryPolicies   map[string]*RetryPolicy // by method or service name
+	HedgingPolicy         *HedgingPolicy
+	MethodHedgingPolicies map[string]*HedgingPolicy // by method or service name
+	CircuitBreaker        *CircuitBreaker
+	Propagation           *Propagation
+	Interceptor           Interceptor
+	Hooks                 *Hooks
+}
+
+// requestCompressor looks up the Compressor for the configured request
+// compression.
+func (cfg *callCfg) requestCompressor(name string) (Compressor, *Error) {
+	compressor, ok := cfg.Compressors.get(name)
+	if !ok {
+		return nil, errorf(CodeInternal, "unknown compression %q: use RegisterCompressor to add it", name)
+	}
+	return compressor, nil
 }
 
 // A CallOption configures a reRPC client or a single call.
@@ -37,6 +63,52 @@ type CallOption interface {
 	applyToCall(*callCfg)
 }
 
+type callTwirpOption struct {
+	ContentType string
+}
+
+func (o *callTwirpOption) applyToCall(cfg *callCfg) {
+	cfg.TwirpContentType = o.ContentType
+}
+
+// CallTwirp configures clients to use the Twirp protocol instead of gRPC. The
+// content type must be either TypeProtoTwirp (for binary protobuf) or TypeJSON;
+// calls made with any other content type fail with CodeInternal. Passing an
+// empty string switches back to gRPC.
+//
+// Twirp doesn't support streaming or trailers, so it's useful for reaching
+// Twirp-only services and for making unary calls through proxies that strip
+// trailers. Streaming calls made with CallTwirp return CodeUnimplemented.
+//
+// The Twirp protocol has no way to send timeouts, so Twirp clients send the
+// remaining time before the context's deadline in the Rerpc-Timeout-Ms header.
+// reRPC handlers honor it, and other Twirp servers ignore it.
+//
+// By default, clients use gRPC.
+func CallTwirp(contentType string) CallOption {
+	return &callTwirpOption{contentType}
+}
+
+type callCompressionOption struct {
+	Name string
+}
+
+func (o *callCompressionOption) applyToCall(cfg *callCfg) {
+	cfg.RequestCompression = o.Name
+}
+
+// CallCompression configures clients to compress requests with the named
+// compression method, which must be CompressionIdentity, CompressionGzip, or
+// a method added with RegisterCompressor. Calls made with unknown methods fail
+// with CodeInternal.
+//
+// Gzip(true) is equivalent to CallCompression(CompressionGzip), and
+// Gzip(false) is equivalent to CallCompression(CompressionIdentity). By
+// default, clients send uncompressed requests.
+func CallCompression(name string) CallOption {
+	return &callCompressionOption{name}
+}
+
 // A Client calls a single method defined by a protocol buffer service. It's
 // the interface between the reRPC library and the client code generated by the
 // reRPC protoc plugin; most users won't ever need to deal with it directly.
@@ -49,6 +121,8 @@ type Client struct {
 	methodFQN   string
 	serviceFQN  string
 	packageFQN  string
+	streamType  StreamType
+	idempotent  bool
 	newResponse func() proto.Message
 	opts        []CallOption
 }
@@ -76,25 +150,54 @@ func NewClient(doer Doer, url, methodFQN, serviceFQN, packageFQN string, newResp
 		methodFQN:   methodFQN,
 		serviceFQN:  serviceFQN,
 		packageFQN:  packageFQN,
+		idempotent:  isIdempotent(methodFQN),
 		newResponse: newResponse,
 		opts:        opts,
 	}
 }
 
+// NewStreamingClient creates a Client for a streaming RPC. Its arguments have
+// the same meaning as NewClient's, except that the message constructors are
+// handled by the generated code, which wraps each Stream in typed helpers.
+//
+// Streaming clients should only use the Stream method.
+func NewStreamingClient(stype StreamType, doer Doer, url, methodFQN, serviceFQN, packageFQN string, opts ...CallOption) *Client {
+	return &Client{
+		doer:       doer,
+		url:        url,
+		methodFQN:  methodFQN,
+		serviceFQN: serviceFQN,
+		packageFQN: packageFQN,
+		streamType: stype,
+		opts:       opts,
+	}
+}
+
 // Call the remote procedure. Any options passed apply only to the current
 // call.
 func (c *Client) Call(ctx context.Context, req proto.Message, opts ...CallOption) (proto.Message, error) {
-	var cfg callCfg
-	for _, opt := range c.opts {
-		opt.applyToCall(&cfg)
-	}
-	for _, opt := range opts {
-		opt.applyToCall(&cfg)
-	}
-
+	cfg := c.config(opts)
 	next := Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
 		// Take care not to return a typed nil from this function.
-		res, err := c.call(ctx, req, &cfg)
+		var res proto.Message
+		var err *Error
+		if breaker := cfg.CircuitBreaker; breaker != nil {
+			md, _ := CallMeta(ctx)
+			probe, rejected := breaker.allow(ctx, &md.Spec, cfg.Hooks)
+			if rejected != nil {
+				return nil, rejected
+			}
+			var sent *int32
+			ctx, sent = withSentFlag(ctx)
+			defer func() {
+				breaker.done(ctx, &md.Spec, cfg.Hooks, probe, err, atomic.LoadInt32(sent) != 0)
+			}()
+		}
+		if policy := cfg.hedgingPolicy(c.methodFQN, c.serviceFQN); policy.maxAttempts() > 1 {
+			res, err = c.callWithHedging(ctx, req, &cfg, policy)
+		} else {
+			res, err = c.callWithRetries(ctx, req, &cfg)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -103,49 +206,209 @@ func (c *Client) Call(ctx context.Context, req proto.Message, opts ...CallOption
 	if cfg.Interceptor != nil {
 		next = cfg.Interceptor.Wrap(next)
 	}
+	ctx, cancel := cfg.Propagation.apply(c.newContext(ctx, &cfg))
+	defer cancel()
+	return next(ctx, req)
+}
+
+// Stream opens a stream to the remote procedure. Any options passed apply
+// only to the current stream. Requests aren't sent to the server until the
+// first call to one of the Stream's methods.
+//
+// Callers must call both CloseSend and CloseReceive on the returned Stream.
+func (c *Client) Stream(ctx context.Context, opts ...CallOption) (Stream, error) {
+	cfg := c.config(opts)
+	next := CallStreamFunc(func(ctx context.Context) (Stream, error) {
+		if cfg.TwirpContentType != "" {
+			return nil, errorf(CodeUnimplemented, "Twirp doesn't support streaming")
+		}
+		if err := setTimeoutHeader(ctx); err != nil {
+			return nil, err
+		}
+		// Take care not to return a typed nil from this function.
+		stream, err := newClientStream(ctx, c.doer, c.url, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		return stream, nil
+	})
+	if si, ok := cfg.Interceptor.(StreamInterceptor); ok {
+		next = si.WrapCallStream(next)
+	}
+	ctx, cancel := cfg.Propagation.apply(c.newContext(ctx, &cfg))
+	stream, err := next(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The stream outlives this function, so release the propagated deadline
+	// when the caller is done with it.
+	return &cancelOnCloseStream{Stream: stream, cancel: cancel}, nil
+}
+
+func (c *Client) config(opts []CallOption) callCfg {
+	cfg := callCfg{Idempotent: c.idempotent}
+	for _, opt := range c.opts {
+		opt.applyToCall(&cfg)
+	}
+	for _, opt := range opts {
+		opt.applyToCall(&cfg)
+	}
+	return cfg
+}
+
+// newContext attaches CallMetadata, including the request headers that
+// interceptors can't modify, to the context.
+func (c *Client) newContext(ctx context.Context, cfg *callCfg) context.Context {
 	spec := &Specification{
+		Type:               c.streamType,
 		Method:             c.methodFQN,
 		Service:            c.serviceFQN,
 		Package:            c.packageFQN,
-		RequestCompression: CompressionGzip,
+		RequestCompression: CompressionIdentity,
 	}
 	if url, err := url.Parse(c.url); err == nil {
 		spec.Path = url.Path
 	}
-	if !cfg.EnableGzipRequest {
-		spec.RequestCompression = CompressionIdentity
+	if cfg.RequestCompression != "" {
+		spec.RequestCompression = cfg.RequestCompression
+	}
+	if ct := cfg.TwirpContentType; ct != "" {
+		spec.ContentType = ct
+		reqHeader := make(http.Header, 4)
+		reqHeader.Set("User-Agent", UserAgent())
+		reqHeader.Set("Content-Type", ct)
+		if spec.RequestCompression != CompressionIdentity {
+			reqHeader.Set("Content-Encoding", spec.RequestCompression)
+		}
+		// Like gRPC clients, always ask for compressed responses.
+		reqHeader.Set("Accept-Encoding", cfg.Compressors.acceptEncoding())
+		return NewCallContext(ctx, *spec, reqHeader, make(http.Header))
 	}
+	spec.ContentType = TypeDefaultGRPC
 	reqHeader := make(http.Header, 5)
 	reqHeader.Set("User-Agent", UserAgent())
 	reqHeader.Set("Content-Type", TypeDefaultGRPC)
 	reqHeader.Set("Grpc-Encoding", spec.RequestCompression)
-	reqHeader.Set("Grpc-Accept-Encoding", acceptEncodingValue) // always advertise identity & gzip
+	reqHeader.Set("Grpc-Accept-Encoding", cfg.Compressors.acceptEncoding()) // always advertise all registered methods
 	reqHeader.Set("Te", "trailers")
-	ctx = NewCallContext(ctx, *spec, reqHeader, make(http.Header))
-	return next(ctx, req)
+	return NewCallContext(ctx, *spec, reqHeader, make(http.Header))
 }
 
-func (c *Client) call(ctx context.Context, req proto.Message, cfg *callCfg) (proto.Message, *Error) {
+func (c *Client) call(ctx context.Context, req proto.Message, cfg *callCfg) (proto.Message, pushback, *Error) {
+	md, hasMD := CallMeta(ctx)
+	if !hasMD {
+		return nil, pushback{}, errorf(CodeInternal, "no call metadata available on context")
+	}
+	if err := setTimeoutHeader(ctx); err != nil {
+		return nil, pushback{}, err
+	}
+	compressor, rerr := cfg.requestCompressor(md.Spec.RequestCompression)
+	if rerr != nil {
+		return nil, pushback{}, rerr
+	}
+
+	body := &bytes.Buffer{}
+	if err := marshalLPM(ctx, body, req, compressor, cfg.CompressMinBytes, cfg.MaxRequestBytes, cfg.Hooks); errors.Is(err, errTooLarge) {
+		return nil, pushback{}, wrap(CodeResourceExhausted, err)
+	} else if err != nil {
+		return nil, pushback{}, errorf(CodeInvalidArgument, "can't marshal request as protobuf: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		return nil, pushback{}, errorf(CodeInternal, "can't create HTTP request: %w", err)
+	}
+	request.Header = md.req.raw
+
+	markSent(ctx)
+	response, err := c.doer.Do(request)
+	if err != nil {
+		return nil, pushback{}, wrapDoerError(ctx, err)
+	}
+	defer response.Body.Close()
+	defer io.Copy(ioutil.Discard, response.Body)
+	*md.res = NewImmutableHeader(response.Header)
+
+	decompressor, rerr := validateResponse(response, cfg.Compressors)
+	if rerr != nil {
+		// Read the body to completion, so any pushback trailer is available.
+		io.Copy(io.Discard, response.Body)
+		return nil, responsePushback(response), reconcileContextError(ctx, rerr, false /* local */)
+	}
+
+	res := c.newResponse()
+	// Handling this error is a little complicated - read on.
+	unmarshalErr := unmarshalLPM(
+		response.Body, res, decompressor,
+		cfg.MaxResponseBytes,
+		decompressedLimit(cfg.MaxResponseBytes, cfg.MaxDecompressedBytes),
+	)
+	// To ensure that we've read the trailers, read the body to completion.
+	io.Copy(io.Discard, response.Body)
+	serverErr := extractError(response.Trailer)
+	if serverErr != nil {
+		// Server sent us an error. In this case, we don't care if the
+		// length-prefixed message was corrupted and unmarshalErr is non-nil.
+		return nil, responsePushback(response), reconcileContextError(ctx, serverErr, false /* local */)
+	} else if errors.Is(unmarshalErr, errTooLarge) {
+		return nil, pushback{}, wrap(CodeResourceExhausted, unmarshalErr)
+	} else if unmarshalErr != nil {
+		// Server thinks response was successful, so unmarshalErr is real. It may
+		// be a read error caused by the context, though.
+		rerr := errorf(CodeUnknown, "server returned invalid protobuf: %w", unmarshalErr)
+		return nil, pushback{}, reconcileContextError(ctx, rerr, true /* local */)
+	}
+	// Server thinks response was successful and so do we, so we're done.
+	return res, pushback{}, nil
+}
+
+func (c *Client) callTwirp(ctx context.Context, req proto.Message, cfg *callCfg) (proto.Message, *Error) {
 	md, hasMD := CallMeta(ctx)
 	if !hasMD {
 		return nil, errorf(CodeInternal, "no call metadata available on context")
 	}
+	if err := setTimeoutHeader(ctx); err != nil {
+		return nil, err
+	}
 
-	if deadline, ok := ctx.Deadline(); ok {
-		untilDeadline := time.Until(deadline)
-		if untilDeadline <= 0 {
-			return nil, errorf(CodeDeadlineExceeded, "no time to make RPC: timeout is %v", untilDeadline)
+	var bs []byte
+	var err error
+	switch md.Spec.ContentType {
+	case TypeJSON:
+		bs, err = jsonpbMarshaler.Marshal(req)
+	case TypeProtoTwirp:
+		bs, err = proto.Marshal(req)
+	default:
+		return nil, errorf(CodeInternal, "unsupported Twirp content type %q", md.Spec.ContentType)
+	}
+	if err != nil {
+		return nil, errorf(CodeInvalidArgument, "can't marshal request: %w", err)
+	}
+	compressor, rerr := cfg.requestCompressor(md.Spec.RequestCompression)
+	if rerr != nil {
+		return nil, rerr
+	}
+	body := &bytes.Buffer{}
+	if compressor == nil || len(bs) < cfg.CompressMinBytes {
+		// Small requests aren't worth compressing.
+		md.req.raw.Del("Content-Encoding")
+		body.Write(bs)
+	} else {
+		cw, err := compressor.Compress(body)
+		if err != nil {
+			return nil, errorf(CodeInternal, "can't compress request: %w", err)
 		}
-		if enc, err := encodeTimeout(untilDeadline); err == nil {
-			// Tests verify that the error in encodeTimeout is unreachable, so we
-			// should be safe without observability for the error case.
-			md.req.raw.Set("Grpc-Timeout", enc)
+		_, err = cw.Write(bs)
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, errorf(CodeInternal, "can't compress request: %w", err)
 		}
 	}
-
-	body := &bytes.Buffer{}
-	if err := marshalLPM(ctx, body, req, md.Spec.RequestCompression, 0 /* maxBytes */, cfg.Hooks); err != nil {
-		return nil, errorf(CodeInvalidArgument, "can't marshal request as protobuf: %w", err)
+	if max := cfg.MaxRequestBytes; max > 0 && body.Len() > max {
+		return nil, wrap(CodeResourceExhausted, fmt.Errorf("%w: got %d bytes, max is %d", errTooLarge, body.Len(), max))
 	}
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
@@ -154,21 +417,141 @@ func (c *Client) call(ctx context.Context, req proto.Message, cfg *callCfg) (pro
 	}
 	request.Header = md.req.raw
 
+	markSent(ctx)
 	response, err := c.doer.Do(request)
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			return nil, errorf(CodeCanceled, "context canceled")
-		}
-		if errors.Is(err, context.DeadlineExceeded) {
-			return nil, errorf(CodeDeadlineExceeded, "context deadline exceeded")
-		}
-		// Error message comes from our networking stack, so it's safe to expose.
-		return nil, wrap(CodeUnknown, err)
+		return nil, wrapDoerError(ctx, err)
 	}
 	defer response.Body.Close()
 	defer io.Copy(ioutil.Discard, response.Body)
 	*md.res = NewImmutableHeader(response.Header)
 
+	resBody := newLimitReader(response.Body, cfg.MaxResponseBytes)
+	if ce := response.Header.Get("Content-Encoding"); ce != "" {
+		decompressor, ok := cfg.Compressors.get(ce)
+		if !ok {
+			return nil, errorf(
+				CodeInternal,
+				"unknown compression %q: accepted content-encoding values are %v",
+				ce,
+				cfg.Compressors.acceptEncoding(),
+			)
+		}
+		if decompressor != nil {
+			dr, err := decompressor.Decompress(resBody)
+			if errors.Is(err, errTooLarge) {
+				return nil, wrap(CodeResourceExhausted, err)
+			} else if err != nil {
+				rerr := errorf(CodeUnknown, "can't read %s-compressed response: %w", ce, err)
+				return nil, reconcileContextError(ctx, rerr, true /* local */)
+			}
+			defer dr.Close()
+			resBody = newLimitReader(dr, decompressedLimit(cfg.MaxResponseBytes, cfg.MaxDecompressedBytes))
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, reconcileContextError(ctx, extractTwirpError(response.StatusCode, resBody), false /* local */)
+	}
+
+	res := c.newResponse()
+	if md.Spec.ContentType == TypeJSON {
+		err = unmarshalJSON(resBody, res)
+	} else {
+		err = unmarshalTwirpProto(resBody, res)
+	}
+	if errors.Is(err, errTooLarge) {
+		return nil, wrap(CodeResourceExhausted, err)
+	} else if err != nil {
+		rerr := errorf(CodeUnknown, "server returned invalid response: %w", err)
+		return nil, reconcileContextError(ctx, rerr, true /* local */)
+	}
+	return res, nil
+}
+
+// setTimeoutHeader propagates the context's deadline to the server. Twirp
+// calls use the Rerpc-Timeout-Ms header, and gRPC calls use Grpc-Timeout.
+func setTimeoutHeader(ctx context.Context) *Error {
+	md, hasMD := CallMeta(ctx)
+	if !hasMD {
+		return errorf(CodeInternal, "no call metadata available on context")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		untilDeadline := time.Until(deadline)
+		if untilDeadline <= 0 {
+			return errorf(CodeDeadlineExceeded, "no time to make RPC: timeout is %v", untilDeadline)
+		}
+		if ct := md.Spec.ContentType; ct == TypeJSON || ct == TypeProtoTwirp {
+			md.req.raw.Set(twirpTimeoutHeader, encodeTwirpTimeout(untilDeadline))
+		} else if enc, err := encodeTimeout(untilDeadline); err == nil {
+			// Tests verify that the error in encodeTimeout is unreachable, so we
+			// should be safe without observability for the error case.
+			md.req.raw.Set("Grpc-Timeout", enc)
+		}
+	}
+	return nil
+}
+
+func wrapDoerError(ctx context.Context, err error) *Error {
+	var rerr *Error
+	if e, ok := AsError(err); ok {
+		// Doers like Balancer may return errors with a more specific code.
+		rerr = e
+	} else if errors.Is(err, context.Canceled) {
+		rerr = wrap(CodeCanceled, context.Canceled)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		rerr = wrap(CodeDeadlineExceeded, context.DeadlineExceeded)
+	} else {
+		// Error message comes from our networking stack, so it's safe to expose.
+		rerr = wrap(CodeUnknown, err)
+	}
+	return reconcileContextError(ctx, rerr, true /* local */)
+}
+
+// reconcileContextError makes a failed call's error agree with its context.
+// Clients and servers detect an expired deadline at slightly different
+// times, so the side that notices first may see the other end's
+// cancellation instead. Following https://github.com/grpc/grpc/pull/15460,
+// once the call's deadline has passed, any cancellation is reported as
+// CodeDeadlineExceeded, even if it came from the server.
+//
+// Local failures, like transport errors and failed reads of the response
+// body, are usually caused by the context when it's done. For those, the
+// context's error replaces the original. Other errors from the server are
+// returned unchanged.
+func reconcileContextError(ctx context.Context, err *Error, local bool) *Error {
+	if err == nil {
+		return nil
+	}
+	if deadlinePassed(ctx) {
+		if local {
+			return wrap(CodeDeadlineExceeded, context.DeadlineExceeded)
+		}
+		if err.code == CodeCanceled {
+			// Keep the server's message and details.
+			return &Error{code: CodeDeadlineExceeded, err: err.err, details: err.details}
+		}
+		return err
+	}
+	if local && ctx.Err() != nil {
+		return wrap(CodeCanceled, context.Canceled)
+	}
+	return err
+}
+
+// deadlinePassed checks the clock as well as the context's error, since the
+// context's timer may not have fired yet.
+func deadlinePassed(ctx context.Context) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// validateResponse checks the HTTP status and headers of a gRPC response. It
+// returns the Compressor used for the response body, which is nil if the body
+// is uncompressed.
+func validateResponse(response *http.Response, comps *compressors) (Compressor, *Error) {
 	if response.StatusCode != http.StatusOK {
 		code := CodeUnknown
 		if c, ok := httpToGRPC[response.StatusCode]; ok {
@@ -180,9 +563,8 @@ func (c *Client) call(ctx context.Context, req proto.Message, cfg *callCfg) (pro
 	if compression == "" {
 		compression = CompressionIdentity
 	}
-	switch compression {
-	case CompressionIdentity, CompressionGzip:
-	default:
+	decompressor, ok := comps.get(compression)
+	if !ok {
 		// Per https://github.com/grpc/grpc/blob/master/doc/compression.md, we
 		// should return CodeInternal and specify acceptable compression(s) (in
 		// addition to setting the Grpc-Accept-Encoding header).
@@ -190,32 +572,39 @@ func (c *Client) call(ctx context.Context, req proto.Message, cfg *callCfg) (pro
 			CodeInternal,
 			"unknown compression %q: accepted grpc-encoding values are %v",
 			compression,
-			acceptEncodingValue,
+			comps.acceptEncoding(),
 		)
 	}
-
 	// When there's no body, errors sent from the first-party gRPC servers will
 	// be in the headers.
 	if err := extractError(response.Header); err != nil {
 		return nil, err
 	}
+	return decompressor, nil
+}
 
-	res := c.newResponse()
-	// Handling this error is a little complicated - read on.
-	unmarshalErr := unmarshalLPM(response.Body, res, compression, cfg.MaxResponseBytes)
-	// To ensure that we've read the trailers, read the body to completion.
-	io.Copy(io.Discard, response.Body)
-	serverErr := extractError(response.Trailer)
-	if serverErr != nil {
-		// Server sent us an error. In this case, we don't care if the
-		// length-prefixed message was corrupted and unmarshalErr is non-nil.
-		return nil, serverErr
-	} else if unmarshalErr != nil {
-		// Server thinks response was successful, so unmarshalErr is real.
-		return nil, errorf(CodeUnknown, "server returned invalid protobuf: %w", unmarshalErr)
+// extractTwirpError converts a Twirp error response into an *Error. Responses
+// that aren't valid Twirp errors (typically from proxies) are classified by
+// their HTTP status code.
+func extractTwirpError(status int, body io.Reader) *Error {
+	var s twirp.Status
+	if err := json.NewDecoder(body).Decode(&s); err != nil || s.Code == "" {
+		code := CodeUnknown
+		if c, ok := httpToGRPC[status]; ok {
+			code = c
+		}
+		return errorf(code, "HTTP status %v", status)
 	}
-	// Server thinks response was successful and so do we, so we're done.
-	return res, nil
+	code, ok := twirpToGRPC[s.Code]
+	if !ok {
+		return errorf(CodeUnknown, "Twirp protocol error: got invalid error code %q", s.Code)
+	}
+	if grpcToTwirp[code] != s.Code {
+		// Preserve Twirp's subtypes of gRPC codes, so handlers propagating this
+		// error send the same Twirp code.
+		return wrap(code, &twirpError{code: s.Code, err: errors.New(s.Message)})
+	}
+	return wrap(code, errors.New(s.Message))
 }
 
 func extractError(h http.Header) *Error {
@@ -0,0 +1,87 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+// expiredContext has a deadline in the past, but its timer hasn't fired yet.
+type expiredContext struct {
+	context.Context
+}
+
+func (expiredContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func TestReconcileContextError(t *testing.T) {
+	live := context.Background()
+	canceled, cancel := context.WithCancel(live)
+	cancel()
+	expired, cancel := context.WithDeadline(live, time.Now().Add(-time.Second))
+	defer cancel()
+	racing := expiredContext{live}
+	assert.Nil(t, racing.Err(), "timer hasn't fired")
+
+	serverCanceled := wrap(CodeCanceled, errors.New("server gave up"))
+	serverUnavailable := wrap(CodeUnavailable, errors.New("server overloaded"))
+	readFailed := wrap(CodeUnknown, errors.New("read failed"))
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		err   *Error
+		local bool
+		want  Code
+	}{
+		{"live_server_canceled", live, serverCanceled, false, CodeCanceled},
+		{"live_local", live, readFailed, true, CodeUnknown},
+		{"canceled_server", canceled, serverUnavailable, false, CodeUnavailable},
+		{"canceled_local", canceled, readFailed, true, CodeCanceled},
+		{"expired_server_canceled", expired, serverCanceled, false, CodeDeadlineExceeded},
+		{"expired_server_other", expired, serverUnavailable, false, CodeUnavailable},
+		{"expired_local", expired, readFailed, true, CodeDeadlineExceeded},
+		{"racing_server_canceled", racing, serverCanceled, false, CodeDeadlineExceeded},
+		{"racing_local", racing, readFailed, true, CodeDeadlineExceeded},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := reconcileContextError(tt.ctx, tt.err, tt.local)
+			assert.Equal(t, err.Code(), tt.want, "code")
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		assert.Nil(t, reconcileContextError(expired, nil, true), "nil error")
+	})
+	t.Run("keeps_server_message", func(t *testing.T) {
+		detailed := wrap(CodeCanceled, errors.New("server gave up"))
+		detail, err := newRetryInfo(time.Second)
+		assert.Nil(t, err, "create detail")
+		assert.Nil(t, detailed.AddDetail(detail), "add detail")
+		reconciled := reconcileContextError(expired, detailed, false)
+		assert.Equal(t, reconciled.Error(), "DeadlineExceeded: server gave up", "message")
+		assert.Equal(t, len(reconciled.Details()), 1, "details")
+	})
+}
+
+func TestWrapDoerError(t *testing.T) {
+	live := context.Background()
+	canceled, cancel := context.WithCancel(live)
+	cancel()
+
+	err := wrapDoerError(live, errors.New("connection refused"))
+	assert.Equal(t, err.Code(), CodeUnknown, "transport error")
+	err = wrapDoerError(live, errorf(CodeUnavailable, "no endpoints"))
+	assert.Equal(t, err.Code(), CodeUnavailable, "passes through *Error")
+	err = wrapDoerError(canceled, errors.New("connection reset"))
+	assert.Equal(t, err.Code(), CodeCanceled, "canceled context")
+	assert.True(t, errors.Is(err, context.Canceled), "wraps context error")
+	err = wrapDoerError(expiredContext{canceled}, context.Canceled)
+	assert.Equal(t, err.Code(), CodeDeadlineExceeded, "canceled after deadline")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "wraps context error")
+}
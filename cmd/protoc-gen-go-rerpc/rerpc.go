@@ -12,6 +12,8 @@ import (
 
 const (
 	contextPackage = protogen.GoImportPath("context")
+	errorsPackage  = protogen.GoImportPath("errors")
+	ioPackage      = protogen.GoImportPath("io")
 	rerpcPackage   = protogen.GoImportPath("github.com/rerpc/rerpc")
 	httpPackage    = protogen.GoImportPath("net/http")
 	protoPackage   = protogen.GoImportPath("google.golang.org/protobuf/proto")
@@ -99,7 +101,7 @@ func clientInterface(g *protogen.GeneratedFile, service *protogen.Service, name
 	}
 	g.Annotate(name, service.Location)
 	g.P("type ", name, " interface {")
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		g.Annotate(name+"."+method.GoName, method.Location)
 		g.P(method.Comments.Leading, clientSignature(g, method))
 	}
@@ -111,6 +113,17 @@ func clientSignature(g *protogen.GeneratedFile, method *protogen.Method) string
 	if method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated() {
 		deprecated(g)
 	}
+	if method.Desc.IsStreamingClient() {
+		return method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", opts ..." + g.QualifiedGoIdent(rerpcPackage.Ident("CallOption")) + ") " +
+			"(*" + clientStreamName(method) + ", error)"
+	}
+	if method.Desc.IsStreamingServer() {
+		return method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", req *" + g.QualifiedGoIdent(method.Input.GoIdent) +
+			", opts ..." + g.QualifiedGoIdent(rerpcPackage.Ident("CallOption")) + ") " +
+			"(*" + clientStreamName(method) + ", error)"
+	}
 	return method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
 		", req *" + g.QualifiedGoIdent(method.Input.GoIdent) +
 		", opts ..." + g.QualifiedGoIdent(rerpcPackage.Ident("CallOption")) + ") " +
@@ -120,7 +133,7 @@ func clientSignature(g *protogen.GeneratedFile, method *protogen.Method) string
 func clientImplementation(g *protogen.GeneratedFile, service *protogen.Service, name string) {
 	// Client struct.
 	g.P("type ", unexport(name), " struct {")
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		g.P(unexport(method.GoName), " ", rerpcPackage.Ident("Client"))
 	}
 	g.P("}")
@@ -140,15 +153,22 @@ func clientImplementation(g *protogen.GeneratedFile, service *protogen.Service,
 		", opts ...", rerpcPackage.Ident("CallOption"), ") ", name, " {")
 	g.P("baseURL = ", stringsPackage.Ident("TrimRight"), `(baseURL, "/")`)
 	g.P("return &", unexport(name), "{")
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		path := fmt.Sprintf("%s/%s", service.Desc.FullName(), method.Desc.Name())
-		g.P(unexport(method.GoName), ": *", rerpcPackage.Ident("NewClient"), "(")
+		if isStreaming(method) {
+			g.P(unexport(method.GoName), ": *", rerpcPackage.Ident("NewStreamingClient"), "(")
+			g.P(rerpcPackage.Ident(streamType(method)), ",")
+		} else {
+			g.P(unexport(method.GoName), ": *", rerpcPackage.Ident("NewClient"), "(")
+		}
 		g.P("doer,")
 		g.P(`baseURL + "/`, path, `", // complete URL to call method`)
 		g.P(`"`, method.Desc.FullName(), `", // fully-qualified protobuf method`)
 		g.P(`"`, service.Desc.FullName(), `", // fully-qualified protobuf service`)
 		g.P(`"`, service.Desc.ParentFile().Package(), `", // fully-qualified protobuf package`)
-		g.P("func() proto.Message { return &", method.Output.GoIdent, "{} }, // response constructor")
+		if !isStreaming(method) {
+			g.P("func() proto.Message { return &", method.Output.GoIdent, "{} }, // response constructor")
+		}
 		g.P("opts...,")
 		g.P("),")
 	}
@@ -157,9 +177,14 @@ func clientImplementation(g *protogen.GeneratedFile, service *protogen.Service,
 	g.P()
 
 	// Client method implementations.
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		clientMethod(g, method)
 	}
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			clientStream(g, method)
+		}
+	}
 }
 
 func clientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
@@ -170,6 +195,38 @@ func clientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
 		deprecated(g)
 	}
 	g.P("func (c *", unexport(method.Parent.GoName), "ClientReRPC) ", clientSignature(g, method), "{")
+	if method.Desc.IsStreamingClient() {
+		g.P("stream, err := c.", unexport(method.GoName), ".Stream(ctx, opts...)")
+		g.P("if err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return &", clientStreamName(method), "{stream: stream}, nil")
+		g.P("}")
+		g.P()
+		return
+	}
+	if method.Desc.IsStreamingServer() {
+		g.P("stream, err := c.", unexport(method.GoName), ".Stream(ctx, opts...)")
+		g.P("if err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		comment(g, "If the server has already closed the stream, Send returns an error ",
+			"wrapping io.EOF. The server's status is available from Receive.")
+		g.P("if err := stream.Send(req); err != nil && !", errorsPackage.Ident("Is"),
+			"(err, ", ioPackage.Ident("EOF"), ") {")
+		g.P("_ = stream.CloseSend(err)")
+		g.P("_ = stream.CloseReceive()")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("if err := stream.CloseSend(nil); err != nil {")
+		g.P("_ = stream.CloseReceive()")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return &", clientStreamName(method), "{stream: stream}, nil")
+		g.P("}")
+		g.P()
+		return
+	}
 	g.P("res, err := c.", unexport(method.GoName), ".Call(ctx, req, opts...)")
 	g.P("if err != nil {")
 	g.P("return nil, err")
@@ -179,6 +236,100 @@ func clientMethod(g *protogen.GeneratedFile, method *protogen.Method) {
 	g.P()
 }
 
+func clientStream(g *protogen.GeneratedFile, method *protogen.Method) {
+	name := clientStreamName(method)
+	comment(g, name, " is the client-side stream for the ", method.Desc.FullName(), " procedure.")
+	if method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated() {
+		g.P("//")
+		deprecated(g)
+	}
+	g.P("type ", name, " struct {")
+	g.P("stream ", rerpcPackage.Ident("Stream"))
+	g.P("}")
+	g.P()
+	if isBidirectional(method) {
+		bidiClientStream(g, method, name)
+		return
+	}
+	if method.Desc.IsStreamingClient() {
+		comment(g, "Send a message to the server. If the server has already closed the ",
+			"stream, Send returns an error wrapping io.EOF; call CloseAndReceive to see ",
+			"the server's status.")
+		g.P("func (s *", name, ") Send(msg *", method.Input.GoIdent, ") error {")
+		g.P("return s.stream.Send(msg)")
+		g.P("}")
+		g.P()
+		comment(g, "CloseAndReceive closes the send side of the stream and waits for ",
+			"the server's response.")
+		g.P("func (s *", name, ") CloseAndReceive() (*", method.Output.GoIdent, ", error) {")
+		g.P("if err := s.stream.CloseSend(nil); err != nil {")
+		g.P("_ = s.stream.CloseReceive()")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("res := &", method.Output.GoIdent, "{}")
+		g.P("if err := s.stream.Receive(res); err != nil {")
+		g.P("_ = s.stream.CloseReceive()")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("if err := s.stream.CloseReceive(); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return res, nil")
+		g.P("}")
+		g.P()
+		return
+	}
+	comment(g, "Receive a message. When the server is done sending messages and no ",
+		"errors have occurred, Receive returns an error wrapping io.EOF.")
+	g.P("func (s *", name, ") Receive() (*", method.Output.GoIdent, ", error) {")
+	g.P("res := &", method.Output.GoIdent, "{}")
+	g.P("if err := s.stream.Receive(res); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return res, nil")
+	g.P("}")
+	g.P()
+	comment(g, "Close the stream. Callers must close the stream, even if they've ",
+		"received all the server's messages.")
+	g.P("func (s *", name, ") Close() error {")
+	g.P("return s.stream.CloseReceive()")
+	g.P("}")
+	g.P()
+}
+
+func bidiClientStream(g *protogen.GeneratedFile, method *protogen.Method, name string) {
+	comment(g, "Send a message to the server. If the server has already closed the ",
+		"stream, Send returns an error wrapping io.EOF; call Receive to see the ",
+		"server's status.")
+	g.P("func (s *", name, ") Send(msg *", method.Input.GoIdent, ") error {")
+	g.P("return s.stream.Send(msg)")
+	g.P("}")
+	g.P()
+	comment(g, "CloseSend closes the send side of the stream, telling the server ",
+		"that no more messages are coming.")
+	g.P("func (s *", name, ") CloseSend() error {")
+	g.P("return s.stream.CloseSend(nil)")
+	g.P("}")
+	g.P()
+	comment(g, "Receive a message. When the server is done sending messages and no ",
+		"errors have occurred, Receive returns an error wrapping io.EOF.")
+	g.P("func (s *", name, ") Receive() (*", method.Output.GoIdent, ", error) {")
+	g.P("res := &", method.Output.GoIdent, "{}")
+	g.P("if err := s.stream.Receive(res); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return res, nil")
+	g.P("}")
+	g.P()
+	comment(g, "CloseReceive closes the receive side of the stream. Callers must ",
+		"close both sides of the stream, even if they've received all the ",
+		"server's messages.")
+	g.P("func (s *", name, ") CloseReceive() error {")
+	g.P("return s.stream.CloseReceive()")
+	g.P("}")
+	g.P()
+}
+
 func serverInterface(g *protogen.GeneratedFile, service *protogen.Service, name string) {
 	comment(g, name, " is a server for the ", service.Desc.FullName(),
 		" service. To make sure that adding methods to this protobuf service doesn't break all ",
@@ -193,7 +344,7 @@ func serverInterface(g *protogen.GeneratedFile, service *protogen.Service, name
 	}
 	g.Annotate(name, service.Location)
 	g.P("type ", name, " interface {")
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		g.Annotate(name+"."+method.GoName, method.Location)
 		g.P(method.Comments.Leading, serverSignature(g, method))
 	}
@@ -206,6 +357,20 @@ func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string
 	if method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated() {
 		deprecated(g)
 	}
+	if isBidirectional(method) {
+		return method.GoName + "(" + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", *" + serverStreamName(method) + ") error"
+	}
+	if method.Desc.IsStreamingClient() {
+		return method.GoName + "(" + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", *" + serverStreamName(method) + ") " +
+			"(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	}
+	if method.Desc.IsStreamingServer() {
+		return method.GoName + "(" + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+			", *" + g.QualifiedGoIdent(method.Input.GoIdent) +
+			", *" + serverStreamName(method) + ") error"
+	}
 	return method.GoName + "(" + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
 		", *" + g.QualifiedGoIdent(method.Input.GoIdent) + ") " +
 		"(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
@@ -223,9 +388,17 @@ func serverConstructor(g *protogen.GeneratedFile, service *protogen.Service, nam
 		") (string, ", httpPackage.Ident("Handler"), ") {")
 	g.P("mux := ", httpPackage.Ident("NewServeMux"), "()")
 	g.P()
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		path := fmt.Sprintf("%s/%s", sname, method.Desc.Name())
 		hname := unexport(string(method.Desc.Name()))
+		if isStreaming(method) {
+			streamingHandler(g, method, hname)
+			g.P(`mux.HandleFunc("/`, path, `", func(w `, httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+			g.P(hname, ".Serve(w, r, nil /* streams construct their own messages */)")
+			g.P("})")
+			g.P()
+			continue
+		}
 		g.P(hname, " := ", rerpcPackage.Ident("NewHandler"), "(")
 		g.P(`"`, method.Desc.FullName(), `", // fully-qualified protobuf method`)
 		g.P(`"`, service.Desc.FullName(), `", // fully-qualified protobuf service`)
@@ -258,6 +431,78 @@ func serverConstructor(g *protogen.GeneratedFile, service *protogen.Service, nam
 	g.P()
 }
 
+func streamingHandler(g *protogen.GeneratedFile, method *protogen.Method, hname string) {
+	g.P(hname, " := ", rerpcPackage.Ident("NewStreamingHandler"), "(")
+	g.P(rerpcPackage.Ident(streamType(method)), ",")
+	g.P(`"`, method.Desc.FullName(), `", // fully-qualified protobuf method`)
+	g.P(`"`, method.Parent.Desc.FullName(), `", // fully-qualified protobuf service`)
+	g.P(`"`, method.Parent.Desc.ParentFile().Package(), `", // fully-qualified protobuf package`)
+	g.P("func(ctx ", contextPackage.Ident("Context"), ", stream ", rerpcPackage.Ident("Stream"), ") error {")
+	if isBidirectional(method) {
+		g.P("return svc.", method.GoName, "(ctx, &", serverStreamName(method), "{stream: stream})")
+		g.P("},")
+		g.P("opts...,")
+		g.P(")")
+		return
+	}
+	if method.Desc.IsStreamingClient() {
+		g.P("res, err := svc.", method.GoName, "(ctx, &", serverStreamName(method), "{stream: stream})")
+		g.P("if err != nil {")
+		g.P("return err")
+		g.P("}")
+		g.P("return stream.Send(res)")
+		g.P("},")
+		g.P("opts...,")
+		g.P(")")
+		return
+	}
+	g.P("req := &", method.Input.GoIdent, "{}")
+	g.P("if err := stream.Receive(req); err != nil {")
+	g.P("return err")
+	g.P("}")
+	g.P("if err := stream.CloseReceive(); err != nil {")
+	g.P("return err")
+	g.P("}")
+	g.P("return svc.", method.GoName, "(ctx, req, &", serverStreamName(method), "{stream: stream})")
+	g.P("},")
+	g.P("opts...,")
+	g.P(")")
+}
+
+func serverStream(g *protogen.GeneratedFile, method *protogen.Method) {
+	name := serverStreamName(method)
+	comment(g, name, " is the server-side stream for the ", method.Desc.FullName(), " procedure.")
+	if method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated() {
+		g.P("//")
+		deprecated(g)
+	}
+	g.P("type ", name, " struct {")
+	g.P("stream ", rerpcPackage.Ident("Stream"))
+	g.P("}")
+	g.P()
+	if method.Desc.IsStreamingClient() {
+		comment(g, "Receive a message. When the client is done sending messages, ",
+			"Receive returns an error wrapping io.EOF.")
+		g.P("func (s *", name, ") Receive() (*", method.Input.GoIdent, ", error) {")
+		g.P("req := &", method.Input.GoIdent, "{}")
+		g.P("if err := s.stream.Receive(req); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return req, nil")
+		g.P("}")
+		g.P()
+	}
+	if !method.Desc.IsStreamingServer() {
+		return
+	}
+	comment(g, "Send a message to the client. To send an error instead, return it ",
+		"from the service implementation.")
+	g.P("func (s *", name, ") Send(msg *", method.Output.GoIdent, ") error {")
+	g.P("return s.stream.Send(msg)")
+	g.P("}")
+	g.P()
+}
+
 func serverImplementation(g *protogen.GeneratedFile, service *protogen.Service, name string) {
 	g.P("var _ ", name, " = (*Unimplemented", name, ")(nil) // verify interface implementation")
 	g.P()
@@ -267,25 +512,52 @@ func serverImplementation(g *protogen.GeneratedFile, service *protogen.Service,
 		" of ", name, " must embed Unimplemented", name, ". ")
 	g.P("type Unimplemented", name, " struct {}")
 	g.P()
-	for _, method := range unaryMethods(service) {
+	for _, method := range service.Methods {
 		g.P("func (Unimplemented", name, ") ", serverSignature(g, method), "{")
-		g.P("return nil, ", rerpcPackage.Ident("Errorf"), "(", rerpcPackage.Ident("CodeUnimplemented"), `, "`, method.Desc.FullName(), ` isn't implemented")`)
+		if method.Desc.IsStreamingServer() {
+			g.P("return ", rerpcPackage.Ident("Errorf"), "(", rerpcPackage.Ident("CodeUnimplemented"), `, "`, method.Desc.FullName(), ` isn't implemented")`)
+		} else {
+			g.P("return nil, ", rerpcPackage.Ident("Errorf"), "(", rerpcPackage.Ident("CodeUnimplemented"), `, "`, method.Desc.FullName(), ` isn't implemented")`)
+		}
 		g.P("}")
 		g.P()
 	}
 	g.P("func (Unimplemented", name, ") mustEmbedUnimplemented", name, "() {}")
 	g.P()
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			serverStream(g, method)
+		}
+	}
 }
 
 func unexport(s string) string { return strings.ToLower(s[:1]) + s[1:] }
 
-func unaryMethods(service *protogen.Service) []*protogen.Method {
-	unary := make([]*protogen.Method, 0, len(service.Methods))
-	for _, m := range service.Methods {
-		if m.Desc.IsStreamingServer() || m.Desc.IsStreamingClient() {
-			continue
-		}
-		unary = append(unary, m)
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingServer() || method.Desc.IsStreamingClient()
+}
+
+func isBidirectional(method *protogen.Method) bool {
+	return method.Desc.IsStreamingServer() && method.Desc.IsStreamingClient()
+}
+
+func streamType(method *protogen.Method) string {
+	if isBidirectional(method) {
+		return "StreamTypeBidirectional"
 	}
-	return unary
+	if method.Desc.IsStreamingClient() {
+		return "StreamTypeClient"
+	}
+	if method.Desc.IsStreamingServer() {
+		return "StreamTypeServer"
+	}
+	return "StreamTypeUnary"
+}
+
+func clientStreamName(method *protogen.Method) string {
+	return method.Parent.GoName + "_" + method.GoName + "ClientReRPC"
+}
+
+func serverStreamName(method *protogen.Method) string {
+	return method.Parent.GoName + "_" + method.GoName + "ServerReRPC"
 }
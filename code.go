@@ -69,6 +69,29 @@ var (
 		CodeDataLoss:           "dataloss",
 		CodeUnauthenticated:    "unauthenticated",
 	}
+	// The inverse of the previous mapping, plus Twirp's special cases of gRPC
+	// codes (see twirpError).
+	twirpToGRPC = map[string]Code{
+		"ok":                  CodeOK,
+		"canceled":            CodeCanceled,
+		"unknown":             CodeUnknown,
+		"invalid_argument":    CodeInvalidArgument,
+		"malformed":           CodeInvalidArgument,
+		"deadline_exceeded":   CodeDeadlineExceeded,
+		"not_found":           CodeNotFound,
+		"bad_route":           CodeUnimplemented,
+		"already_exists":      CodeAlreadyExists,
+		"permission_denied":   CodePermissionDenied,
+		"resource_exhausted":  CodeResourceExhausted,
+		"failed_precondition": CodeFailedPrecondition,
+		"aborted":             CodeAborted,
+		"out_of_range":        CodeOutOfRange,
+		"unimplemented":       CodeUnimplemented,
+		"internal":            CodeInternal,
+		"unavailable":         CodeUnavailable,
+		"dataloss":            CodeDataLoss,
+		"unauthenticated":     CodeUnauthenticated,
+	}
 	// From https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md.
 	// Note that these are not the inverse of the previous mapping.
 	httpToGRPC = map[int]Code{
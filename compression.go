@@ -0,0 +1,202 @@
+package rerpc
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A Compressor implements a compression method, like gzip or zstd. Clients and
+// handlers use Compressors for gRPC's per-message compression and for the
+// HTTP-level compression used by Twirp. Implementations must be safe to call
+// concurrently.
+//
+// ReRPC includes a gzip Compressor, registered as CompressionGzip. To support
+// other compression methods, see RegisterCompressor.
+type Compressor interface {
+	// Compress returns a writer that compresses data and writes it to the
+	// supplied writer. Closing the returned writer must flush any buffered
+	// data, but must not close the underlying writer.
+	Compress(io.Writer) (io.WriteCloser, error)
+	// Decompress returns a reader that decompresses data read from the
+	// supplied reader. Callers close the returned reader once they're done
+	// reading.
+	Decompress(io.Reader) (io.ReadCloser, error)
+}
+
+type compressorOption struct {
+	Name       string
+	Compressor Compressor
+}
+
+// RegisterCompressor makes a compression method available to clients and
+// handlers. The name must be the value used in the Grpc-Encoding and
+// Content-Encoding headers (e.g., "zstd" or "snappy").
+//
+// Clients and handlers decompress messages with any registered method and
+// advertise every registered method in the Grpc-Accept-Encoding header.
+// Handlers choose the response compression using the client's quality values
+// (as in "gzip;q=0.5, zstd"); among methods the client finds equally
+// acceptable, handlers prefer the most recently registered one. To compress
+// requests with a registered method, clients must also use CallCompression.
+//
+// Registering a Compressor under an existing name (including
+// CompressionGzip) replaces the previous Compressor, and registering a nil
+// Compressor removes the compression method. The identity method can't be
+// replaced or removed.
+func RegisterCompressor(name string, c Compressor) Option {
+	return &compressorOption{Name: name, Compressor: c}
+}
+
+func (o *compressorOption) applyToCall(cfg *callCfg) {
+	cfg.Compressors = cfg.Compressors.register(o.Name, o.Compressor)
+}
+
+func (o *compressorOption) applyToHandler(cfg *handlerCfg) {
+	cfg.Compressors = cfg.Compressors.register(o.Name, o.Compressor)
+}
+
+// compressors is a registry of compression methods. A nil *compressors
+// contains only the default methods, gzip and identity.
+type compressors struct {
+	names  []string // in order of preference, excluding identity
+	byName map[string]Compressor
+}
+
+func newCompressors() *compressors {
+	return &compressors{
+		names:  []string{CompressionGzip},
+		byName: map[string]Compressor{CompressionGzip: &gzipCompressor{}},
+	}
+}
+
+// register adds a Compressor to the registry, allocating a registry if
+// necessary.
+func (cs *compressors) register(name string, c Compressor) *compressors {
+	if cs == nil {
+		cs = newCompressors()
+	}
+	if name == "" || name == CompressionIdentity {
+		return cs
+	}
+	names := make([]string, 0, len(cs.names)+1)
+	if c != nil {
+		names = append(names, name)
+		cs.byName[name] = c
+	} else {
+		delete(cs.byName, name)
+	}
+	for _, n := range cs.names {
+		if n != name {
+			names = append(names, n)
+		}
+	}
+	cs.names = names
+	return cs
+}
+
+// get looks up a compression method by name. It returns a nil Compressor and
+// true for the identity method.
+func (cs *compressors) get(name string) (Compressor, bool) {
+	if cs == nil {
+		cs = defaultCompressors
+	}
+	if name == CompressionIdentity {
+		return nil, true
+	}
+	c, ok := cs.byName[name]
+	return c, ok
+}
+
+// acceptEncoding lists the registered methods, in order of preference, as a
+// comma-separated list suitable for the Grpc-Accept-Encoding header.
+func (cs *compressors) acceptEncoding() string {
+	if cs == nil {
+		cs = defaultCompressors
+	}
+	if len(cs.names) == 0 {
+		return CompressionIdentity
+	}
+	return strings.Join(cs.names, ",") + "," + CompressionIdentity
+}
+
+// negotiate chooses a compression method from an Accept-Encoding or
+// Grpc-Accept-Encoding header, following RFC 7231 section 5.3.4: the client's
+// quality values take precedence, and ties go to the method the registry
+// prefers. Identity is an acceptable last resort unless the header explicitly
+// excludes it. Methods for which skip returns true are never chosen. If the
+// header is empty or doesn't allow any registered method, negotiate returns
+// false.
+func (cs *compressors) negotiate(header string, skip func(string) bool) (string, bool) {
+	if cs == nil {
+		cs = defaultCompressors
+	}
+	qualities := make(map[string]float64)
+	wildcard := -1.0 // no wildcard
+	for _, part := range strings.Split(header, ",") {
+		name, q, ok := parseQuality(part)
+		if !ok {
+			continue
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		qualities[name] = q
+	}
+	if len(qualities) == 0 && wildcard < 0 {
+		return "", false
+	}
+	const lastResort = 0.0001 // below any quality a client can send
+	best, bestQ := "", 0.0
+	for _, name := range append(cs.names[:len(cs.names):len(cs.names)], CompressionIdentity) {
+		if skip != nil && skip(name) {
+			continue
+		}
+		q, ok := qualities[name]
+		if !ok {
+			switch {
+			case wildcard >= 0:
+				q = wildcard
+			case name == CompressionIdentity:
+				q = lastResort
+			default:
+				q = 0
+			}
+		}
+		// Names are in order of preference, so ties go to earlier names.
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best, best != ""
+}
+
+// parseQuality parses one element of an Accept-Encoding header, like
+// "gzip;q=0.5". Content-codings are case-insensitive, so the returned name is
+// lowercase.
+func parseQuality(part string) (string, float64, bool) {
+	name, params := part, ""
+	if i := strings.IndexByte(part, ';'); i >= 0 {
+		name, params = part[:i], part[i+1:]
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", 0, false
+	}
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(param[2:]), 64)
+		if err != nil || v < 0 || v > 1 {
+			return "", 0, false
+		}
+		q = v
+	}
+	return name, q, true
+}
+
+var defaultCompressors = newCompressors()
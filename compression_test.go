@@ -0,0 +1,71 @@
+package rerpc
+
+import (
+	"testing"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestCompressors(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		var cs *compressors
+		assert.Equal(t, cs.acceptEncoding(), "gzip,identity", "accept encoding")
+		c, ok := cs.get(CompressionGzip)
+		assert.True(t, ok, "gzip registered")
+		assert.NotNil(t, c, "gzip compressor")
+		c, ok = cs.get(CompressionIdentity)
+		assert.True(t, ok, "identity registered")
+		assert.Nil(t, c, "identity compressor")
+		_, ok = cs.get("zstd")
+		assert.False(t, ok, "zstd registered")
+	})
+	t.Run("register", func(t *testing.T) {
+		cs := (*compressors)(nil).register("zstd", &gzipCompressor{})
+		cs = cs.register("snappy", &gzipCompressor{})
+		assert.Equal(t, cs.acceptEncoding(), "snappy,zstd,gzip,identity", "accept encoding")
+		cs = cs.register(CompressionIdentity, &gzipCompressor{})
+		assert.Equal(t, cs.acceptEncoding(), "snappy,zstd,gzip,identity", "identity is fixed")
+	})
+	t.Run("remove", func(t *testing.T) {
+		cs := (*compressors)(nil).register(CompressionGzip, nil)
+		assert.Equal(t, cs.acceptEncoding(), "identity", "accept encoding")
+		_, ok := cs.get(CompressionGzip)
+		assert.False(t, ok, "gzip registered")
+	})
+}
+
+func TestNegotiateCompression(t *testing.T) {
+	cs := (*compressors)(nil).register("zstd", &gzipCompressor{})
+	noGzip := func(name string) bool { return name == CompressionGzip }
+	tests := []struct {
+		header string
+		skip   func(string) bool
+		expect string // empty if negotiation fails
+	}{
+		{header: "", expect: ""},
+		{header: "br", expect: CompressionIdentity},
+		{header: "gzip", expect: CompressionGzip},
+		{header: "GZIP", expect: CompressionGzip},
+		{header: "gzip, zstd", expect: "zstd"}, // server preference breaks ties
+		{header: "zstd, gzip", expect: "zstd"},
+		{header: "gzip,identity", expect: CompressionGzip},
+		{header: "zstd;q=0.5, gzip", expect: CompressionGzip},
+		{header: "zstd;q=0.5, gzip;q=0.8", expect: CompressionGzip},
+		{header: "gzip;q=0", expect: CompressionIdentity},
+		{header: "gzip;q=0, identity;q=0", expect: ""},
+		{header: "*", expect: "zstd"},
+		{header: "*;q=0.1, gzip;q=0.2", expect: CompressionGzip},
+		{header: "*;q=0", expect: ""},
+		{header: "*;q=0, identity", expect: CompressionIdentity},
+		{header: "gzip;q=2, zstd;q=abc", expect: ""}, // invalid qualities
+		{header: "gzip;q=2, br", expect: CompressionIdentity},
+		{header: "gzip ; q=0.9 , zstd ;Q=0.1", expect: CompressionGzip},
+		{header: "gzip", skip: noGzip, expect: CompressionIdentity},
+		{header: "*", skip: noGzip, expect: "zstd"},
+	}
+	for _, tt := range tests {
+		got, ok := cs.negotiate(tt.header, tt.skip)
+		assert.Equal(t, got, tt.expect, "negotiate %q", assert.Fmt(tt.header))
+		assert.Equal(t, ok, tt.expect != "", "negotiate %q succeeded", assert.Fmt(tt.header))
+	}
+}
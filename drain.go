@@ -0,0 +1,125 @@
+package rerpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A HealthShutdowner can report every service as HealthNotServing, regardless
+// of its usual statuses. HealthServer and HealthAggregator both implement
+// HealthShutdowner.
+type HealthShutdowner interface {
+	Shutdown()
+}
+
+// A Drainer shuts down a server gracefully. Without one, health checks keep
+// reporting HealthServing until the process exits, so load balancers keep
+// sending new calls while the server is shutting down, and those calls race
+// with in-flight calls to finish before the process exits. Drainer's Shutdown
+// method instead:
+//
+//  1. Reports every service as HealthNotServing, using Health.
+//  2. Waits for PropagationDelay, so load balancers and clients watching
+//     health have time to stop sending new requests.
+//  3. Rejects new calls with CodeUnavailable (HTTP 503 for Twirp), which
+//     clients may safely retry on another replica.
+//  4. Shuts down the Server, which closes its listeners and idle
+//     connections.
+//  5. Waits for calls that were already in flight to finish.
+//
+// Drainers are valid HandlerOptions, and they only track and reject calls to
+// the handlers they're passed to. To drain a whole server, pass the same
+// Drainer to every handler except the health handler, which should keep
+// reporting HealthNotServing while the server drains. Interceptors still see
+// rejected calls. A Drainer's fields must not be modified after its first use,
+// and Drainers must not be copied after first use. They're safe to use
+// concurrently.
+type Drainer struct {
+	// Server is shut down once new calls are rejected. If nil, Drainers only
+	// drain handlers.
+	Server *http.Server
+	// Health, if non-nil, is shut down before anything else.
+	Health HealthShutdowner
+	// PropagationDelay is how long to wait between updating health and
+	// rejecting new calls.
+	PropagationDelay time.Duration
+
+	mu       sync.Mutex
+	draining bool
+	inflight int
+	idle     chan struct{} // closed when the last in-flight call finishes
+}
+
+func (d *Drainer) applyToHandler(cfg *handlerCfg) {
+	cfg.Drainer = d
+}
+
+// Shutdown drains the server, as described above. If the context expires
+// before in-flight calls finish, Shutdown returns the context's error; call
+// the Server's Close method to cancel the remaining calls. Like the
+// Server's Shutdown method, it doesn't wait for hijacked connections.
+func (d *Drainer) Shutdown(ctx context.Context) error {
+	if d.Health != nil {
+		d.Health.Shutdown()
+	}
+	if d.PropagationDelay > 0 {
+		timer := time.NewTimer(d.PropagationDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// Stop waiting, but still drain: the caller is out of time.
+		}
+		timer.Stop()
+	}
+
+	d.mu.Lock()
+	d.draining = true
+	var idle chan struct{}
+	if d.inflight > 0 {
+		if d.idle == nil {
+			d.idle = make(chan struct{})
+		}
+		idle = d.idle
+	}
+	d.mu.Unlock()
+
+	var err error
+	if d.Server != nil {
+		err = d.Server.Shutdown(ctx)
+	}
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+	}
+	return err
+}
+
+// enter tracks a call, rejecting it if the Drainer is draining. If it returns
+// a nil error, the caller must call the returned function once the call
+// finishes.
+func (d *Drainer) enter(spec *Specification) (func(), *Error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, errorf(CodeUnavailable, "server is shutting down: can't call %s", spec.Method)
+	}
+	d.inflight++
+	return d.exit, nil
+}
+
+func (d *Drainer) exit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight--
+	if d.inflight == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
@@ -0,0 +1,73 @@
+package rerpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestDrainer(t *testing.T) {
+	spec := &Specification{Method: "acme.foo.v1.FooService.Bar"}
+	reg := NewRegistrar()
+	health := NewHealthServer(reg)
+	drainer := &Drainer{Health: health}
+
+	exit, err := drainer.enter(spec)
+	assert.Nil(t, err, "enter before shutdown")
+
+	done := make(chan error, 1)
+	go func() { done <- drainer.Shutdown(context.Background()) }()
+
+	// Wait for Shutdown to start draining.
+	timeout := time.After(5 * time.Second)
+	for {
+		if _, err := drainer.enter(spec); err != nil {
+			assert.Equal(t, CodeOf(err), CodeUnavailable, "enter while draining")
+			break
+		}
+		drainer.exit()
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for Shutdown to reject calls")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	status, checkErr := health.Check(context.Background(), "")
+	assert.Nil(t, checkErr, "check health")
+	assert.Equal(t, status, HealthNotServing, "health while draining")
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v with a call in flight", err)
+	default:
+	}
+	exit()
+	select {
+	case err := <-done:
+		assert.Nil(t, err, "shutdown")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Shutdown to return")
+	}
+}
+
+func TestDrainerDeadline(t *testing.T) {
+	spec := &Specification{Method: "acme.foo.v1.FooService.Bar"}
+	drainer := &Drainer{PropagationDelay: time.Minute}
+	exit, err := drainer.enter(spec)
+	assert.Nil(t, err, "enter before shutdown")
+	defer exit()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	shutdownErr := drainer.Shutdown(ctx)
+	assert.Equal(t, shutdownErr, context.DeadlineExceeded, "shutdown with call in flight")
+	_, err = drainer.enter(spec)
+	assert.Equal(t, CodeOf(err), CodeUnavailable, "enter after shutdown")
+}
+
+func TestDrainerIdle(t *testing.T) {
+	drainer := &Drainer{}
+	assert.Nil(t, drainer.Shutdown(context.Background()), "shutdown without calls")
+}
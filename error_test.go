@@ -51,3 +51,28 @@ func TestErrorDetails(t *testing.T) {
 	assert.Nil(t, rerr.SetDetails(second, second), "overwrite details")
 	assert.Equal(t, rerr.Details(), []*anypb.Any{detail, detail}, "retrieve details")
 }
+
+func TestExtractTwirpError(t *testing.T) {
+	t.Run("standard", func(t *testing.T) {
+		err := extractTwirpError(404, strings.NewReader(`{"code": "not_found", "msg": "oops"}`))
+		assert.Equal(t, err.Code(), CodeNotFound, "code")
+		assert.Equal(t, err.Error(), "NotFound: oops", "message")
+		_, ok := asTwirpError(err)
+		assert.False(t, ok, "Twirp subtype")
+	})
+	t.Run("subtype", func(t *testing.T) {
+		err := extractTwirpError(400, strings.NewReader(`{"code": "malformed", "msg": "oops"}`))
+		assert.Equal(t, err.Code(), CodeInvalidArgument, "code")
+		te, ok := asTwirpError(err)
+		assert.True(t, ok, "Twirp subtype")
+		assert.Equal(t, te.TwirpCode(), "malformed", "Twirp code")
+	})
+	t.Run("intermediary", func(t *testing.T) {
+		err := extractTwirpError(503, strings.NewReader("<html>Service Unavailable</html>"))
+		assert.Equal(t, err.Code(), CodeUnavailable, "code")
+	})
+	t.Run("invalid_code", func(t *testing.T) {
+		err := extractTwirpError(500, strings.NewReader(`{"code": "foo", "msg": "oops"}`))
+		assert.Equal(t, err.Code(), CodeUnknown, "code")
+	})
+}
@@ -32,9 +32,12 @@ func Example() {
 	// middleware (e.g., net/http's StripPrefix).
 	mux := http.NewServeMux()
 	mux.Handle(pingpb.NewPingServiceHandlerReRPC(ping, reg)) // business logic
-	mux.Handle(rerpc.NewReflectionHandler(reg))              // server reflection
 	mux.Handle(rerpc.NewHealthHandler(checker, reg))         // health checks
 	mux.Handle("/", rerpc.NewBadRouteHandler())              // Twirp-compatible 404s
+	paths, reflection := rerpc.NewReflectionHandler(reg)     // server reflection
+	for _, path := range paths {
+		mux.Handle(path, reflection)
+	}
 
 	// Timeouts, connection handling, TLS configuration, and other low-level
 	// transport details are handled by net/http. Everything you already know (or
@@ -3,7 +3,6 @@ package rerpc
 import (
 	"compress/gzip"
 	"io"
-	"net/http"
 	"sync"
 )
 
@@ -13,31 +12,34 @@ var gzWriterPool = sync.Pool{
 	},
 }
 
-// Verify we're implementing these interfaces at compile time.
-var (
-	_ http.ResponseWriter = &gzipResponseWriter{}
-	_ http.Flusher        = &gzipResponseWriter{}
-)
+// gzipCompressor is the default Compressor, registered as CompressionGzip. It
+// pools gzip writers, which are expensive to allocate.
+type gzipCompressor struct{}
 
-type gzipResponseWriter struct {
-	http.ResponseWriter
+var _ Compressor = (*gzipCompressor)(nil)
 
-	gw *gzip.Writer
+func (g *gzipCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	gw := gzWriterPool.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return &pooledGzipWriter{gw}, nil
 }
 
-func (w *gzipResponseWriter) Flush() {
-	w.gw.Flush()
-	f, ok := w.ResponseWriter.(http.Flusher)
-	if ok {
-		f.Flush()
-	}
+func (g *gzipCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
 }
 
-func (w *gzipResponseWriter) WriteHeader(status int) {
-	w.ResponseWriter.Header().Del("Content-Length")
-	w.ResponseWriter.WriteHeader(status)
+// pooledGzipWriter returns its gzip.Writer to the pool on Close.
+type pooledGzipWriter struct {
+	*gzip.Writer
 }
 
-func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.gw.Write(b)
+func (w *pooledGzipWriter) Close() error {
+	if w.Writer == nil {
+		return nil // already closed
+	}
+	err := w.Writer.Close()
+	w.Writer.Reset(io.Discard) // don't keep references
+	gzWriterPool.Put(w.Writer)
+	w.Writer = nil
+	return err
 }
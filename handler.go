@@ -1,9 +1,10 @@
 package rerpc
 
 import (
-	"compress/gzip"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -18,8 +19,6 @@ import (
 )
 
 var (
-	// Always advertise that reRPC accepts gzip compression.
-	acceptEncodingValue    = strings.Join([]string{CompressionGzip, CompressionIdentity}, ",")
 	acceptPostValueDefault = strings.Join(
 		[]string{TypeDefaultGRPC, TypeProtoGRPC, TypeJSON},
 		",",
@@ -31,12 +30,18 @@ var (
 )
 
 type handlerCfg struct {
-	DisableGzipResponse bool
-	DisableTwirp        bool
-	MaxRequestBytes     int
-	Registrar           *Registrar
-	Interceptor         Interceptor
-	Hooks               *Hooks
+	DisableGzipResponse  bool
+	DisableTwirp         bool
+	MaxRequestBytes      int
+	MaxDecompressedBytes int
+	MaxResponseBytes     int
+	CompressMinBytes     int
+	Compressors          *compressors
+	ConcurrencyLimiter   *ConcurrencyLimiter
+	Drainer              *Drainer
+	Registrar            *Registrar
+	Interceptor          Interceptor
+	Hooks                *Hooks
 }
 
 // A HandlerOption configures a Handler.
@@ -59,6 +64,14 @@ func (o *serveTwirpOption) applyToHandler(cfg *handlerCfg) {
 // formats. Disable Twirp if you only want your handlers to speak the gRPC
 // protocol.
 //
+// The Twirp protocol doesn't include timeouts, so handlers accept them in
+// either of two headers. Rerpc-Timeout-Ms holds a whole number of
+// milliseconds (for example, "Rerpc-Timeout-Ms: 1500"), which is easy to set
+// from browsers and scripts. Handlers also accept gRPC's Grpc-Timeout header.
+// If a request has both, Rerpc-Timeout-Ms takes precedence. Handlers apply the
+// timeout to the request context before any Interceptors run, so ClampTimeout
+// works the same way for Twirp and gRPC requests.
+//
 // By default, handlers support Twirp.
 func ServeTwirp(enable bool) HandlerOption {
 	return &serveTwirpOption{!enable}
@@ -72,21 +85,13 @@ func ServeTwirp(enable bool) HandlerOption {
 // To see an example of how Handler is used in the generated code, see the
 // internal/pingpb/v0 package.
 type Handler struct {
+	stype          StreamType
 	methodFQN      string
 	serviceFQN     string
 	packageFQN     string
 	implementation Func
-	// rawGRPC is used only for our hand-rolled reflection handler, which needs
-	// bidi streaming
-	rawGRPC func(
-		context.Context,
-		http.ResponseWriter,
-		*http.Request,
-		string, // request compression
-		string, // response compression
-		*Hooks,
-	)
-	config handlerCfg
+	stream         func(context.Context, Stream) error
+	config         handlerCfg
 }
 
 // NewHandler constructs a Handler. The supplied method, service, and package
@@ -113,14 +118,45 @@ func NewHandler(methodFQN, serviceFQN, packageFQN string, impl Func, opts ...Han
 	}
 }
 
+// NewStreamingHandler constructs a Handler for a streaming RPC. The
+// supplied method, service, and package must be fully-qualified protobuf
+// identifiers, just as they are for NewHandler.
+//
+// The implementation receives a Stream for each call. Any error it returns
+// is sent to the client as a gRPC status. Because the Twirp protocol doesn't
+// support streaming, streaming handlers only speak gRPC. Handlers for client
+// and bidirectional streaming RPCs also require HTTP/2: over HTTP/1.x, they
+// respond with http.StatusHTTPVersionNotSupported.
+func NewStreamingHandler(
+	stype StreamType,
+	methodFQN, serviceFQN, packageFQN string,
+	impl func(context.Context, Stream) error,
+	opts ...HandlerOption,
+) *Handler {
+	opts = append(opts, ServeTwirp(false))
+	h := NewHandler(methodFQN, serviceFQN, packageFQN, nil /* unary impl */, opts...)
+	h.stype = stype
+	h.stream = impl
+	return h
+}
+
 // Serve executes the handler, much like the standard library's http.Handler.
 // Unlike http.Handler, it requires a pointer to the generated request struct.
 // See the internal/ping/v1test package for an example of how this code is used
 // in reRPC's generated code.
 //
 // As long as the caller allocates a new request struct for each call, this
-// method is safe to call concurrently.
+// method is safe to call concurrently. Streaming handlers construct their
+// messages as they go, so they ignore the request struct; callers may pass
+// nil.
 func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Message) {
+	if max := h.config.MaxRequestBytes; max > 0 && h.stype == StreamTypeUnary {
+		// Unary requests hold a single message, so there's no reason to read
+		// past the limit (plus room for a gRPC length prefix). If the client
+		// sends more, the server closes the connection rather than draining an
+		// unbounded body.
+		r.Body = http.MaxBytesReader(w, r.Body, int64(max)+5)
+	}
 	// To ensure that we can re-use connections, always consume and close the
 	// request body.
 	defer r.Body.Close()
@@ -135,6 +171,7 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 	}
 
 	spec := &Specification{
+		Type:                h.stype,
 		Method:              h.methodFQN,
 		Service:             h.serviceFQN,
 		Package:             h.packageFQN,
@@ -165,6 +202,12 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 	var failed *Error
 
 	timeout, err := parseTimeout(r.Header.Get("Grpc-Timeout"))
+	if spec.ContentType == TypeJSON || spec.ContentType == TypeProtoTwirp {
+		if header := r.Header.Get(twirpTimeoutHeader); header != "" {
+			// Prefer the Twirp-specific header if clients send both.
+			timeout, err = parseTwirpTimeout(header)
+		}
+	}
 	if err != nil && err != errNoTimeout {
 		// Errors here indicate that the client sent an invalid timeout header, so
 		// the error text is safe to send back.
@@ -176,53 +219,48 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 	} // else err == errNoTimeout, nothing to do
 
 	if spec.ContentType == TypeJSON || spec.ContentType == TypeProtoTwirp {
-		if r.Header.Get("Content-Encoding") == "gzip" {
-			spec.RequestCompression = CompressionGzip
+		if ce := r.Header.Get("Content-Encoding"); ce != "" {
+			if _, ok := h.config.Compressors.get(ce); ok {
+				spec.RequestCompression = ce
+			} else if failed == nil {
+				failed = errorf(
+					CodeUnimplemented,
+					"unknown compression %q: accepted content-encoding values are %v",
+					ce, h.config.Compressors.acceptEncoding(),
+				)
+			}
 		}
-		// TODO: Actually parse Accept-Encoding instead of this hackery.
-		if !h.config.DisableGzipResponse && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			spec.ResponseCompression = CompressionGzip
+		if enc, ok := h.negotiateResponseCompression(r.Header.Get("Accept-Encoding")); ok {
+			spec.ResponseCompression = enc
 		}
+		// The response depends on Accept-Encoding, so caches must take it into
+		// account.
+		w.Header().Add("Vary", "Accept-Encoding")
 	} else {
-		spec.RequestCompression = CompressionIdentity
 		if me := r.Header.Get("Grpc-Encoding"); me != "" {
-			switch me {
-			case CompressionIdentity:
-				spec.RequestCompression = CompressionIdentity
-			case CompressionGzip:
-				spec.RequestCompression = CompressionGzip
-			default:
+			if _, ok := h.config.Compressors.get(me); ok {
+				spec.RequestCompression = me
+			} else if failed == nil {
 				// Per https://github.com/grpc/grpc/blob/master/doc/compression.md, we
 				// should return CodeUnimplemented and specify acceptable compression(s)
 				// (in addition to setting the Grpc-Accept-Encoding header).
-				if failed == nil {
-					failed = errorf(
-						CodeUnimplemented,
-						"unknown compression %q: accepted grpc-encoding values are %v",
-						me, acceptEncodingValue,
-					)
-				}
+				failed = errorf(
+					CodeUnimplemented,
+					"unknown compression %q: accepted grpc-encoding values are %v",
+					me, h.config.Compressors.acceptEncoding(),
+				)
 			}
 		}
 		// Follow https://github.com/grpc/grpc/blob/master/doc/compression.md.
 		// (The grpc-go implementation doesn't read the "grpc-accept-encoding" header
 		// and doesn't support compression method asymmetry.)
 		spec.ResponseCompression = spec.RequestCompression
-		if h.config.DisableGzipResponse {
+		if spec.ResponseCompression == CompressionGzip && h.config.DisableGzipResponse {
 			spec.ResponseCompression = CompressionIdentity
-		} else if mae := r.Header.Get("Grpc-Accept-Encoding"); mae != "" {
-			for _, enc := range strings.FieldsFunc(mae, splitOnCommasAndSpaces) {
-				switch enc {
-				case CompressionGzip:
-					spec.ResponseCompression = CompressionGzip
-					// prefer gzip, so no continue
-				case CompressionIdentity:
-					spec.ResponseCompression = CompressionIdentity
-					continue
-				default:
-					continue
-				}
-				break
+		}
+		if mae := r.Header.Get("Grpc-Accept-Encoding"); mae != "" {
+			if enc, ok := h.negotiateResponseCompression(mae); ok {
+				spec.ResponseCompression = enc
 			}
 		}
 	}
@@ -231,7 +269,7 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 	// set headers here.
 	w.Header().Set("Content-Type", spec.ContentType)
 	if spec.ContentType != TypeJSON && spec.ContentType != TypeProtoTwirp {
-		w.Header().Set("Grpc-Accept-Encoding", acceptEncodingValue)
+		w.Header().Set("Grpc-Accept-Encoding", h.config.Compressors.acceptEncoding())
 		w.Header().Set("Grpc-Encoding", spec.ResponseCompression)
 		// Every gRPC response will have these trailers.
 		w.Header().Add("Trailer", "Grpc-Status")
@@ -239,7 +277,31 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 		w.Header().Add("Trailer", "Grpc-Status-Details-Bin")
 	}
 
+	// Reject new calls during shutdown, and track the rest so shutdown can
+	// wait for them.
+	if drainer := h.config.Drainer; drainer != nil && failed == nil {
+		if exit, err := drainer.enter(spec); err != nil {
+			failed = err
+		} else {
+			defer exit()
+		}
+	}
+
+	// Shed load before reading the body, but let interceptors see the
+	// rejection.
+	if limiter := h.config.ConcurrencyLimiter; limiter != nil && failed == nil {
+		if release, err := limiter.acquire(spec); err != nil {
+			failed = err
+		} else {
+			defer func() { release(r.Context().Err() == context.DeadlineExceeded) }()
+		}
+	}
+
 	ctx := NewHandlerContext(r.Context(), *spec, r.Header, w.Header())
+	if h.stype != StreamTypeUnary {
+		h.serveStream(ctx, w, r, spec, failed)
+		return
+	}
 	var implementation Func
 	if failed != nil {
 		implementation = Func(func(context.Context, proto.Message) (proto.Message, error) {
@@ -256,27 +318,27 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, req proto.Messag
 
 func (h *Handler) implementationTwirp(w http.ResponseWriter, r *http.Request, spec *Specification) Func {
 	return Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
-		var body io.Reader = r.Body
-		if spec.RequestCompression == CompressionGzip {
-			gr, err := gzip.NewReader(body)
-			if err != nil {
-				return nil, errorf(CodeInvalidArgument, "can't read gzipped body")
-			}
-			defer gr.Close()
-			body = gr
-		}
-		if max := h.config.MaxRequestBytes; max > 0 {
-			body = &io.LimitedReader{
-				R: body,
-				N: int64(max),
+		body := newLimitReader(r.Body, h.config.MaxRequestBytes)
+		if decompressor, _ := h.config.Compressors.get(spec.RequestCompression); decompressor != nil {
+			dr, err := decompressor.Decompress(body)
+			if errors.Is(err, errTooLarge) {
+				return nil, wrap(CodeResourceExhausted, err)
+			} else if err != nil {
+				return nil, errorf(CodeInvalidArgument, "can't read %s-compressed body", spec.RequestCompression)
 			}
+			defer dr.Close()
+			body = newLimitReader(dr, decompressedLimit(h.config.MaxRequestBytes, h.config.MaxDecompressedBytes))
 		}
 		if spec.ContentType == TypeJSON {
-			if err := unmarshalJSON(body, req); err != nil {
+			if err := unmarshalJSON(body, req); errors.Is(err, errTooLarge) {
+				return nil, wrap(CodeResourceExhausted, err)
+			} else if err != nil {
 				return nil, wrap(CodeInvalidArgument, newMalformedError("can't unmarshal JSON body"))
 			}
 		} else {
-			if err := unmarshalTwirpProto(body, req); err != nil {
+			if err := unmarshalTwirpProto(body, req); errors.Is(err, errTooLarge) {
+				return nil, wrap(CodeResourceExhausted, err)
+			} else if err != nil {
 				return nil, wrap(CodeInvalidArgument, newMalformedError("can't unmarshal Twirp protobuf body"))
 			}
 		}
@@ -286,17 +348,42 @@ func (h *Handler) implementationTwirp(w http.ResponseWriter, r *http.Request, sp
 
 func (h *Handler) implementationGRPC(w http.ResponseWriter, r *http.Request, spec *Specification) Func {
 	return Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
-		if raw := h.rawGRPC; raw != nil {
-			raw(ctx, w, r, spec.RequestCompression, spec.ResponseCompression, h.config.Hooks)
-			return nil, nil
-		}
-		if err := unmarshalLPM(r.Body, req, spec.RequestCompression, h.config.MaxRequestBytes); err != nil {
+		decompressor, _ := h.config.Compressors.get(spec.RequestCompression)
+		err := unmarshalLPM(
+			r.Body, req, decompressor,
+			h.config.MaxRequestBytes,
+			decompressedLimit(h.config.MaxRequestBytes, h.config.MaxDecompressedBytes),
+		)
+		if errors.Is(err, errTooLarge) {
+			return nil, wrap(CodeResourceExhausted, err)
+		} else if err != nil {
 			return nil, errorf(CodeInvalidArgument, "can't unmarshal protobuf body")
 		}
 		return h.implementation(ctx, req)
 	})
 }
 
+func (h *Handler) serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request, spec *Specification, failed *Error) {
+	if h.stype&StreamTypeClient != 0 && r.ProtoMajor < 2 {
+		// net/http can't read the request body after it starts writing the
+		// response over HTTP/1.x, so client streaming requires HTTP/2.
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusHTTPVersionNotSupported)
+		io.WriteString(w, streamingName(h.stype)+" requires HTTP/2")
+		return
+	}
+	var implementation HandlerStreamFunc
+	if failed != nil {
+		implementation = HandlerStreamFunc(func(context.Context, Stream) error {
+			return failed
+		})
+	} else {
+		implementation = HandlerStreamFunc(h.stream)
+	}
+	stream := newServerStream(ctx, w, r.Body, spec, &h.config)
+	stream.CloseSend(h.wrapStream(implementation)(ctx, stream))
+}
+
 func (h *Handler) writeResult(ctx context.Context, w http.ResponseWriter, spec *Specification, res proto.Message, err error) {
 	if spec.ContentType == TypeJSON || spec.ContentType == TypeProtoTwirp {
 		h.writeResultTwirp(ctx, w, spec, res, err)
@@ -306,30 +393,66 @@ func (h *Handler) writeResult(ctx context.Context, w http.ResponseWriter, spec *
 }
 
 func (h *Handler) writeResultTwirp(ctx context.Context, w http.ResponseWriter, spec *Specification, res proto.Message, err error) {
-	// Even if the client requested gzip compression, check Content-Encoding to
-	// make sure some other HTTP middleware hasn't already swapped out the
+	// Buffer the body, so we know the status code, whether the body is large
+	// enough to compress, and whether it's too large to send before we write
+	// any headers.
+	body := &bytes.Buffer{}
+	status := http.StatusOK
+	if err != nil {
+		// Twirp always writes errors as JSON, even if the caller sends
+		// TypeProtoTwirp.
+		w.Header().Set("Content-Type", TypeJSON)
+		status = marshalErrorJSON(ctx, body, err, h.config.Hooks)
+	} else if spec.ContentType == TypeJSON {
+		marshalJSON(ctx, body, res, h.config.Hooks)
+	} else {
+		marshalTwirpProto(ctx, body, res, h.config.Hooks)
+	}
+	body, encoding := h.compressTwirp(ctx, w, spec, body)
+	if max := h.config.MaxResponseBytes; max > 0 && status == http.StatusOK && body.Len() > max {
+		tooLarge := fmt.Errorf("%w: got %d bytes, max is %d", errTooLarge, body.Len(), max)
+		body, encoding = &bytes.Buffer{}, ""
+		w.Header().Set("Content-Type", TypeJSON)
+		status = marshalErrorJSON(ctx, body, wrap(CodeResourceExhausted, tooLarge), h.config.Hooks)
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.WriteHeader(status)
+	if _, err := body.WriteTo(w); err != nil {
+		h.config.Hooks.onNetworkError(ctx, fmt.Errorf("couldn't write Twirp response: %w", err))
+	}
+}
+
+// compressTwirp compresses a Twirp response body if the client accepts
+// compression and the body is large enough to be worth compressing. It
+// returns the body to send and its Content-Encoding, which is empty if the
+// body is uncompressed.
+func (h *Handler) compressTwirp(ctx context.Context, w http.ResponseWriter, spec *Specification, body *bytes.Buffer) (*bytes.Buffer, string) {
+	// Even if the client requested compression, check Content-Encoding to make
+	// sure some other HTTP middleware hasn't already swapped out the
 	// ResponseWriter.
-	if spec.ResponseCompression == CompressionGzip && w.Header().Get("Content-Encoding") == "" {
-		w.Header().Set("Content-Encoding", "gzip")
-		gw := gzWriterPool.Get().(*gzip.Writer)
-		gw.Reset(w)
-		w = &gzipResponseWriter{ResponseWriter: w, gw: gw}
-		defer func() {
-			gw.Close()           // close if we haven't already
-			gw.Reset(io.Discard) // don't keep references
-			gzWriterPool.Put(gw)
-		}()
+	compressor, _ := h.config.Compressors.get(spec.ResponseCompression)
+	if compressor == nil || body.Len() < h.config.CompressMinBytes || w.Header().Get("Content-Encoding") != "" {
+		return body, ""
 	}
+	compressed := &bytes.Buffer{}
+	cw, err := compressor.Compress(compressed)
 	if err != nil {
-		// Twirp always writes errors as JSON.
-		writeErrorJSON(ctx, w, err, h.config.Hooks)
-		return
+		// Fall back to an uncompressed response.
+		h.config.Hooks.onInternalError(ctx, fmt.Errorf("couldn't create compressor: %w", err))
+		return body, ""
 	}
-	if spec.ContentType == TypeJSON {
-		marshalJSON(ctx, w, res, h.config.Hooks)
-	} else {
-		marshalTwirpProto(ctx, w, res, h.config.Hooks)
+	_, err = cw.Write(body.Bytes())
+	if cerr := cw.Close(); err == nil {
+		err = cerr
 	}
+	if err != nil {
+		h.config.Hooks.onInternalError(ctx, fmt.Errorf("couldn't compress response: %w", err))
+		return body, ""
+	}
+	return compressed, spec.ResponseCompression
 }
 
 func (h *Handler) writeResultGRPC(ctx context.Context, w http.ResponseWriter, spec *Specification, res proto.Message, err error) {
@@ -337,7 +460,12 @@ func (h *Handler) writeResultGRPC(ctx context.Context, w http.ResponseWriter, sp
 		writeErrorGRPC(ctx, w, err, h.config.Hooks)
 		return
 	}
-	if err := marshalLPM(ctx, w, res, spec.ResponseCompression, 0 /* maxBytes */, h.config.Hooks); err != nil {
+	compressor, _ := h.config.Compressors.get(spec.ResponseCompression)
+	if err := marshalLPM(ctx, w, res, compressor, h.config.CompressMinBytes, h.config.MaxResponseBytes, h.config.Hooks); errors.Is(err, errTooLarge) {
+		// marshalLPM checks the size before writing anything.
+		writeErrorGRPC(ctx, w, wrap(CodeResourceExhausted, err), h.config.Hooks)
+		return
+	} else if err != nil {
 		// It's safe to write gRPC errors even after we've started writing the
 		// body.
 		writeErrorGRPC(ctx, w, errorf(CodeUnknown, "can't marshal protobuf response"), h.config.Hooks)
@@ -353,30 +481,39 @@ func (h *Handler) wrap(next Func) Func {
 	return next
 }
 
-func splitOnCommasAndSpaces(c rune) bool {
-	return c == ',' || c == ' '
+func (h *Handler) wrapStream(next HandlerStreamFunc) HandlerStreamFunc {
+	if si, ok := h.config.Interceptor.(StreamInterceptor); ok {
+		return si.WrapHandlerStream(next)
+	}
+	return next
+}
+
+// negotiateResponseCompression chooses a compression method for the response
+// from the client's Accept-Encoding or Grpc-Accept-Encoding header.
+func (h *Handler) negotiateResponseCompression(header string) (string, bool) {
+	return h.config.Compressors.negotiate(header, func(name string) bool {
+		return name == CompressionGzip && h.config.DisableGzipResponse
+	})
 }
 
-func writeErrorJSON(ctx context.Context, w http.ResponseWriter, err error, hooks *Hooks) {
-	// Even if the caller sends TypeProtoTwirp, we respond with TypeJSON on errors.
-	w.Header().Set("Content-Type", TypeJSON)
+// marshalErrorJSON writes a Twirp error to w and returns the HTTP status code
+// for the response.
+func marshalErrorJSON(ctx context.Context, w io.Writer, err error, hooks *Hooks) int {
 	s := newTwirpStatus(err)
 	bs, merr := json.Marshal(s)
 	if merr != nil {
 		hooks.onMarshalError(ctx, merr)
-		w.WriteHeader(http.StatusInternalServerError)
 		// codes don't need to be escaped in JSON, so this is okay
 		const tmpl = `{"code": "%s", "msg": "error marshaling error with code %s"}`
 		if _, nerr := fmt.Fprintf(w, tmpl, CodeInternal.twirp(), s.Code); nerr != nil {
 			hooks.onNetworkError(ctx, nerr)
 		}
-		return
+		return http.StatusInternalServerError
 	}
-	w.WriteHeader(CodeOf(err).http())
-	_, err = w.Write(bs)
-	if err != nil {
+	if _, err := w.Write(bs); err != nil {
 		hooks.onNetworkError(ctx, err)
 	}
+	return CodeOf(err).http()
 }
 
 func writeErrorGRPC(ctx context.Context, w http.ResponseWriter, err error, hooks *Hooks) {
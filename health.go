@@ -4,32 +4,74 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
 	healthpb "github.com/rerpc/rerpc/internal/health/v1"
 )
 
+// healthPollInterval is how often Watch re-checks health when there's no
+// HealthNotifier.
+const healthPollInterval = 5 * time.Second
+
+// Fully-qualified protobuf names for gRPC's health-checking API.
+const (
+	healthPackageFQN = "grpc.health.v1"
+	healthServiceFQN = healthPackageFQN + ".Health"
+)
+
 // HealthStatus describes the health of a service.
 //
-// These correspond to the ServingStatus enum in gRPC's health.proto. Because
-// reRPC doesn't support watching health, SERVICE_UNKNOWN isn't exposed here.
-//
-// For details, see the protobuf schema:
+// These correspond to the ServingStatus enum in gRPC's health.proto. For
+// details, see the protobuf schema:
 // https://github.com/grpc/grpc/blob/master/src/proto/grpc/health/v1/health.proto.
 type HealthStatus int32
 
 const (
-	HealthUnknown    HealthStatus = 0 // health state indeterminate
-	HealthServing    HealthStatus = 1 // ready to accept requests
-	HealthNotServing HealthStatus = 2 // process healthy but service not accepting requests
+	HealthUnknown        HealthStatus = 0 // health state indeterminate
+	HealthServing        HealthStatus = 1 // ready to accept requests
+	HealthNotServing     HealthStatus = 2 // process healthy but service not accepting requests
+	HealthServiceUnknown HealthStatus = 3 // service not registered, used only by Watch
 )
 
+// String returns the name of the corresponding value in gRPC's ServingStatus
+// enum (for example, "NOT_SERVING" for HealthNotServing).
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthUnknown:
+		return "UNKNOWN"
+	case HealthServing:
+		return "SERVING"
+	case HealthNotServing:
+		return "NOT_SERVING"
+	case HealthServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	}
+	return fmt.Sprintf("HealthStatus(%d)", int32(s))
+}
+
+// A HealthNotifier tells health-checking handlers when the health of any
+// service may have changed, so they can push updates to clients of the
+// streaming Watch method. Changed returns a channel that's closed at the next
+// change. Once a channel is closed, Changed must return a new channel for the
+// change after that.
+//
+// Notifications are only hints: after each one, handlers re-run their
+// health-checking function and send the result only if it differs from the
+// last status the client saw. Implementations must be safe to use
+// concurrently.
+type HealthNotifier interface {
+	Changed() <-chan struct{}
+}
+
 // NewChecker returns a health-checking function that always returns
 // HealthServing for the process and all registered services. It's safe to call
 // concurrently.
 //
-// The returned function can be passed to NewHealthHandler.
+// The returned function can be passed to NewHealthHandler. To change
+// statuses at runtime, use a HealthServer instead.
 func NewChecker(reg *Registrar) func(context.Context, string) (HealthStatus, error) {
 	return func(_ context.Context, service string) (HealthStatus, error) {
 		if service == "" {
@@ -42,6 +84,139 @@ func NewChecker(reg *Registrar) func(context.Context, string) (HealthStatus, err
 	}
 }
 
+// A HealthServer tracks the health of a process and its services. Unlike the
+// function returned by NewChecker, it lets callers change each service's
+// status: for example, to mark a service HealthNotServing while its database
+// is unreachable, or to drain the whole process before shutting down.
+//
+// Services default to HealthServing once they're registered with the
+// HealthServer's Registrar, and the process as a whole (the empty service
+// name) always starts out HealthServing. HealthServers implement
+// HealthNotifier, so changes reach clients of the streaming Watch method
+// immediately:
+//
+//   health := rerpc.NewHealthServer(reg)
+//   mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health, reg))
+//
+// It's safe to use a HealthServer concurrently.
+type HealthServer struct {
+	reg *Registrar
+
+	mu       sync.RWMutex
+	statuses map[string]HealthStatus
+	shutdown bool
+	changed  healthBroadcast
+}
+
+var _ HealthNotifier = (*HealthServer)(nil)
+
+// NewHealthServer constructs a HealthServer. The Registrar may be nil, in
+// which case only the process and services with explicitly-set statuses are
+// known.
+func NewHealthServer(reg *Registrar) *HealthServer {
+	return &HealthServer{
+		reg:      reg,
+		statuses: make(map[string]HealthStatus),
+	}
+}
+
+// Check returns the health of a fully-qualified protobuf service, or of the
+// whole process if the service name is empty. It returns a CodeNotFound error
+// for unknown services. Check satisfies the requirements of NewHealthHandler.
+func (h *HealthServer) Check(_ context.Context, service string) (HealthStatus, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.lookup(service)
+	if !ok {
+		return HealthUnknown, errorf(CodeNotFound, "unknown service %s", service)
+	}
+	if h.shutdown {
+		return HealthNotServing, nil
+	}
+	return status, nil
+}
+
+// lookup returns a service's status, ignoring Shutdown, and whether the
+// service is known. Callers must hold the lock.
+func (h *HealthServer) lookup(service string) (HealthStatus, bool) {
+	if status, ok := h.statuses[service]; ok {
+		return status, true
+	}
+	if service == "" || (h.reg != nil && h.reg.IsRegistered(service)) {
+		return HealthServing, true
+	}
+	return HealthUnknown, false
+}
+
+// SetStatus sets the health of a fully-qualified protobuf service, or of the
+// whole process if the service name is empty. Setting a status makes the
+// service known, even if it's not registered. While the HealthServer is shut
+// down, new statuses take effect only after Resume.
+func (h *HealthServer) SetStatus(service string, status HealthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old, ok := h.lookup(service)
+	h.statuses[service] = status
+	if !h.shutdown && (!ok || old != status) {
+		h.changed.notify()
+	}
+}
+
+// Shutdown reports every known service, and the process as a whole, as
+// HealthNotServing. Call it when the process starts draining, so load
+// balancers stop sending new requests before the server stops accepting
+// connections.
+func (h *HealthServer) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.shutdown {
+		h.shutdown = true
+		h.changed.notify()
+	}
+}
+
+// Resume undoes Shutdown, so every service reports the status most recently
+// set with SetStatus (or HealthServing, if none was set).
+func (h *HealthServer) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		h.shutdown = false
+		h.changed.notify()
+	}
+}
+
+// Changed implements HealthNotifier.
+func (h *HealthServer) Changed() <-chan struct{} {
+	return h.changed.wait()
+}
+
+// healthBroadcast implements HealthNotifier's channels: it closes the current
+// channel on each change, and it creates a new one on demand. The zero value
+// is ready to use.
+type healthBroadcast struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func (b *healthBroadcast) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ch == nil {
+		b.ch = make(chan struct{})
+	}
+	return b.ch
+}
+
+func (b *healthBroadcast) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ch != nil {
+		close(b.ch)
+		b.ch = nil
+	}
+}
+
 // NewHealthHandler wraps the supplied function to build an HTTP handler for
 // gRPC's health-checking API. It returns the HTTP handler and the correct path
 // on which to mount it. The health-checking function will be called with a
@@ -51,12 +226,16 @@ func NewChecker(reg *Registrar) func(context.Context, string) (HealthStatus, err
 // HealthServing, or HealthNotServing; (2) return the health status of the
 // whole process when called with an empty string; (3) return a
 // CodeNotFound error when called with an unknown service; and (4) be safe to
-// call concurrently. The function returned by NewChecker satisfies all these
-// requirements.
+// call concurrently. The function returned by NewChecker and the Check method
+// of HealthServer satisfy all these requirements.
 //
-// Note that the returned handler only supports the unary Check method, not the
-// streaming Watch. As suggested in gRPC's health schema, reRPC returns
-// CodeUnimplemented for the Watch method. For more details on gRPC's health
+// The returned handler also supports the streaming Watch method. Watch sends
+// the service's current status, then sends a new message each time the status
+// changes. Unknown services have status HealthServiceUnknown rather than an
+// error, since they may be registered later. Watch re-checks health every
+// five seconds; to push changes to watchers promptly, use
+// NewNotifyingHealthHandler instead. Like other streaming methods, Watch
+// doesn't support the Twirp protocol. For more details on gRPC's health
 // checking protocol, see:
 //   https://github.com/grpc/grpc/blob/master/doc/health-checking.md
 //   https://github.com/grpc/grpc/blob/master/src/proto/grpc/health/v1/health.proto
@@ -64,8 +243,28 @@ func NewHealthHandler(
 	check func(context.Context, string) (HealthStatus, error),
 	opts ...HandlerOption,
 ) (string, http.Handler) {
-	const packageFQN = "grpc.health.v1"
-	const serviceFQN = packageFQN + ".Health"
+	return newHealthHandler(check, nil /* notifier */, opts)
+}
+
+// NewNotifyingHealthHandler is like NewHealthHandler, but the handler's Watch
+// method re-checks health each time the HealthNotifier reports a change
+// rather than polling. HealthServer and HealthAggregator both implement
+// HealthNotifier.
+func NewNotifyingHealthHandler(
+	check func(context.Context, string) (HealthStatus, error),
+	notifier HealthNotifier,
+	opts ...HandlerOption,
+) (string, http.Handler) {
+	return newHealthHandler(check, notifier, opts)
+}
+
+func newHealthHandler(
+	check func(context.Context, string) (HealthStatus, error),
+	notifier HealthNotifier,
+	opts []HandlerOption,
+) (string, http.Handler) {
+	const packageFQN = healthPackageFQN
+	const serviceFQN = healthServiceFQN
 	const checkFQN = serviceFQN + ".Check"
 	const watchFQN = serviceFQN + ".Watch"
 
@@ -98,18 +297,88 @@ func NewHealthHandler(
 		checkHandler.Serve(w, r, &healthpb.HealthCheckRequest{})
 	})
 
-	watch := NewHandler(
+	watch := NewStreamingHandler(
+		StreamTypeServer,
 		watchFQN,
 		serviceFQN,
 		packageFQN,
-		func(ctx context.Context, req proto.Message) (proto.Message, error) {
-			return nil, errorf(CodeUnimplemented, "reRPC doesn't support watching health state")
+		func(ctx context.Context, stream Stream) error {
+			req := &healthpb.HealthCheckRequest{}
+			if err := stream.Receive(req); err != nil {
+				return err
+			}
+			if err := stream.CloseReceive(); err != nil {
+				return err
+			}
+			return watchHealth(ctx, stream, req.Service, check, notifier)
 		},
 		opts...,
 	)
 	mux.HandleFunc(fmt.Sprintf("/%s/Watch", serviceFQN), func(w http.ResponseWriter, r *http.Request) {
-		watch.Serve(w, r, &healthpb.HealthCheckRequest{})
+		watch.Serve(w, r, nil /* streams construct their own messages */)
 	})
 
 	return fmt.Sprintf("/%s/", serviceFQN), mux
 }
+
+// watchHealth implements the Watch method, sending the service's status each
+// time it changes until the client goes away.
+func watchHealth(
+	ctx context.Context,
+	stream Stream,
+	service string,
+	check func(context.Context, string) (HealthStatus, error),
+	notifier HealthNotifier,
+) error {
+	sent := false
+	var last HealthStatus
+	for {
+		// Subscribe before checking, so we can't miss a change.
+		var changed <-chan struct{}
+		if notifier != nil {
+			changed = notifier.Changed()
+		}
+		status, err := check(ctx, service)
+		if CodeOf(err) == CodeNotFound {
+			status, err = HealthServiceUnknown, nil
+		}
+		if err != nil {
+			return err
+		}
+		if !sent || status != last {
+			res := &healthpb.HealthCheckResponse{
+				Status: healthpb.HealthCheckResponse_ServingStatus(status),
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+			sent, last = true, status
+		}
+
+		if err := waitForHealthChange(ctx, changed); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForHealthChange blocks until the changed channel is closed or, if it's
+// nil, until it's time to poll again.
+func waitForHealthChange(ctx context.Context, changed <-chan struct{}) error {
+	var poll <-chan time.Time
+	if changed == nil {
+		timer := time.NewTimer(healthPollInterval)
+		defer timer.Stop()
+		poll = timer.C
+	}
+	select {
+	case <-changed:
+		return nil
+	case <-poll:
+		return nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return wrap(CodeDeadlineExceeded, ctx.Err())
+		}
+		return wrap(CodeCanceled, ctx.Err())
+	}
+}
@@ -0,0 +1,68 @@
+package rerpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestHealthServer(t *testing.T) {
+	const registered, unregistered = "acme.foo.v1.FooService", "acme.bar.v1.BarService"
+	reg := NewRegistrar()
+	reg.register(registered)
+	health := NewHealthServer(reg)
+	check := func(t testing.TB, service string, expect HealthStatus) {
+		t.Helper()
+		status, err := health.Check(context.Background(), service)
+		assert.Nil(t, err, "check %q", assert.Fmt(service))
+		assert.Equal(t, status, expect, "status of %q", assert.Fmt(service))
+	}
+	isClosed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	check(t, "", HealthServing)
+	check(t, registered, HealthServing)
+	_, err := health.Check(context.Background(), unregistered)
+	assert.Equal(t, CodeOf(err), CodeNotFound, "unregistered service")
+
+	changed := health.Changed()
+	health.SetStatus(registered, HealthServing)
+	assert.False(t, isClosed(changed), "notified without change")
+	health.SetStatus(registered, HealthNotServing)
+	assert.True(t, isClosed(changed), "notified of change")
+	check(t, registered, HealthNotServing)
+	health.SetStatus(unregistered, HealthServing)
+	check(t, unregistered, HealthServing)
+
+	changed = health.Changed()
+	health.Shutdown()
+	assert.True(t, isClosed(changed), "notified of shutdown")
+	check(t, "", HealthNotServing)
+	check(t, unregistered, HealthNotServing)
+	changed = health.Changed()
+	health.SetStatus(registered, HealthServing)
+	assert.False(t, isClosed(changed), "notified while shut down")
+	check(t, registered, HealthNotServing)
+
+	health.Resume()
+	assert.True(t, isClosed(changed), "notified of resume")
+	check(t, "", HealthServing)
+	check(t, registered, HealthServing)
+	check(t, unregistered, HealthServing)
+}
+
+func TestHealthServerNilRegistrar(t *testing.T) {
+	health := NewHealthServer(nil)
+	status, err := health.Check(context.Background(), "")
+	assert.Nil(t, err, "check process")
+	assert.Equal(t, status, HealthServing, "process status")
+	_, err = health.Check(context.Background(), "acme.foo.v1.FooService")
+	assert.Equal(t, CodeOf(err), CodeNotFound, "unknown service")
+}
@@ -0,0 +1,239 @@
+package rerpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// A HedgingPolicy configures clients to hedge unary calls to idempotent
+// methods: rather than waiting for a slow attempt to fail, clients send
+// additional attempts in parallel through the same Doer, use the first
+// successful or fatal result, and cancel the rest. Hedging trades extra load
+// on the server for lower tail latency, so it's best suited to read-heavy
+// services.
+//
+// Clients only hedge calls to idempotent methods. A method is idempotent if
+// its protobuf definition sets the idempotency_level option to
+// NO_SIDE_EFFECTS or IDEMPOTENT; CallIdempotent overrides the protobuf
+// definition. Calls to other methods ignore the HedgingPolicy and fall back to
+// any configured RetryPolicy. Like retries, hedging happens inside the
+// interceptor chain, never extends the call's deadline, and doesn't apply to
+// streaming calls.
+//
+// Once an attempt fails with a non-fatal code, clients send the next attempt
+// immediately. gRPC servers may delay the next attempt with the
+// grpc-retry-pushback-ms trailer, or stop further attempts with a negative
+// pushback.
+//
+// HedgingPolicies are valid CallOptions. By default, clients don't hedge.
+type HedgingPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the original
+	// call. Values below 2 disable hedging.
+	MaxAttempts int
+	// Delay is the time to wait for a result before sending the next attempt.
+	// If zero, clients send all attempts at once.
+	Delay time.Duration
+	// NonFatalCodes lists the error codes that don't end the call: after one
+	// attempt fails with a non-fatal code, clients continue to wait for the
+	// other attempts. If empty, only CodeUnavailable is non-fatal.
+	NonFatalCodes []Code
+	// Methods limits the policy to the listed fully-qualified protobuf methods
+	// or services, using the same precedence rules as RetryPolicy.
+	Methods []string
+}
+
+func (p *HedgingPolicy) applyToCall(cfg *callCfg) {
+	if len(p.Methods) == 0 {
+		cfg.HedgingPolicy = p
+		return
+	}
+	// As with RetryPolicy, each call builds a fresh configuration.
+	if cfg.MethodHedgingPolicies == nil {
+		cfg.MethodHedgingPolicies = make(map[string]*HedgingPolicy, len(p.Methods))
+	}
+	for _, name := range p.Methods {
+		cfg.MethodHedgingPolicies[name] = p
+	}
+}
+
+func (p *HedgingPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *HedgingPolicy) nonFatal(code Code) bool {
+	if len(p.NonFatalCodes) == 0 {
+		return code == CodeUnavailable
+	}
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// hedgingPolicy finds the policy for a method, using the same precedence as
+// retryPolicy. It returns nil if the call shouldn't be hedged.
+func (cfg *callCfg) hedgingPolicy(methodFQN, serviceFQN string) *HedgingPolicy {
+	if !cfg.Idempotent {
+		return nil
+	}
+	if p, ok := cfg.MethodHedgingPolicies[methodFQN]; ok {
+		return p
+	}
+	if p, ok := cfg.MethodHedgingPolicies[serviceFQN]; ok {
+		return p
+	}
+	return cfg.HedgingPolicy
+}
+
+type callIdempotentOption struct {
+	Idempotent bool
+}
+
+func (o *callIdempotentOption) applyToCall(cfg *callCfg) {
+	cfg.Idempotent = o.Idempotent
+}
+
+// CallIdempotent marks calls as safe (or unsafe) to hedge, overriding the
+// idempotency_level option in the method's protobuf definition. Since the
+// generated constructors apply options to every method in a service, it's
+// usually best to pass CallIdempotent to individual calls.
+//
+// By default, clients use the protobuf definition: methods are idempotent if
+// they're marked NO_SIDE_EFFECTS or IDEMPOTENT.
+func CallIdempotent(idempotent bool) CallOption {
+	return &callIdempotentOption{idempotent}
+}
+
+// isIdempotent checks the idempotency_level option in the method's protobuf
+// definition. Methods that aren't in the global registry aren't idempotent.
+func isIdempotent(methodFQN string) bool {
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(methodFQN))
+	if err != nil {
+		return false
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+	opts, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok {
+		return false
+	}
+	switch opts.GetIdempotencyLevel() {
+	case descriptorpb.MethodOptions_NO_SIDE_EFFECTS, descriptorpb.MethodOptions_IDEMPOTENT:
+		return true
+	default:
+		return false
+	}
+}
+
+// newAttemptContext gives a hedged attempt its own copy of the call's
+// metadata, so concurrent attempts don't share header maps.
+func newAttemptContext(ctx context.Context, md CallMetadata) (context.Context, CallMetadata) {
+	req := NewMutableHeader(md.req.raw.Clone())
+	res := NewImmutableHeader(make(http.Header))
+	attempt := CallMetadata{
+		Spec: md.Spec,
+		req:  &req,
+		res:  &res,
+	}
+	return context.WithValue(ctx, callMetaKey, attempt), attempt
+}
+
+// callWithHedging makes a unary call, hedging according to the supplied
+// policy.
+func (c *Client) callWithHedging(ctx context.Context, req proto.Message, cfg *callCfg, policy *HedgingPolicy) (proto.Message, *Error) {
+	md, ok := CallMeta(ctx)
+	if !ok {
+		return nil, errorf(CodeInternal, "no call metadata available on context")
+	}
+	// Canceling the context stops any outstanding attempts once we have a
+	// result.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		res proto.Message
+		pb  pushback
+		md  CallMetadata
+		err *Error
+	}
+	attempts := policy.maxAttempts()
+	// Buffer the channel so that abandoned attempts don't block forever.
+	results := make(chan result, attempts)
+	var sent, pending int
+	send := func() {
+		attemptCtx, attemptMD := newAttemptContext(ctx, md)
+		sent++
+		pending++
+		go func() {
+			res, pb, err := c.attempt(attemptCtx, req, cfg)
+			results <- result{res: res, pb: pb, md: attemptMD, err: err}
+		}()
+	}
+	// hedge fires when it's time to send another attempt. A nil channel never
+	// fires.
+	var hedge <-chan time.Time
+	var timer *time.Timer
+	scheduleHedge := func(delay time.Duration) {
+		if timer != nil {
+			timer.Stop()
+		}
+		hedge = nil
+		if sent >= attempts || ctx.Err() != nil {
+			return
+		}
+		timer = time.NewTimer(delay)
+		hedge = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	send()
+	scheduleHedge(policy.Delay)
+	for {
+		select {
+		case <-hedge:
+			send()
+			scheduleHedge(policy.Delay)
+		case r := <-results:
+			pending--
+			if r.err == nil || !policy.nonFatal(r.err.Code()) || (pending == 0 && sent >= attempts) {
+				*md.res = *r.md.res
+				if r.err != nil {
+					return nil, r.err
+				}
+				return r.res, nil
+			}
+			switch {
+			case r.pb.ok && r.pb.delay < 0:
+				// The server asked us to stop, so don't send any more attempts.
+				sent = attempts
+				scheduleHedge(0)
+			case r.pb.ok:
+				scheduleHedge(r.pb.delay)
+			default:
+				scheduleHedge(0)
+			}
+			if pending == 0 && hedge == nil {
+				// Nothing outstanding and nothing left to send.
+				*md.res = *r.md.res
+				return nil, r.err
+			}
+		}
+	}
+}
@@ -23,6 +23,11 @@ type Hooks struct {
 	// this class of errors should only crop up if you're using non-standard
 	// protobuf code generation.
 	OnMarshalError func(context.Context, error)
+	// OnCircuitStateChange observes a CircuitBreaker's circuits opening,
+	// closing, and becoming half-open. The name is the fully-qualified
+	// protobuf method or service, and the context is from the call that
+	// triggered the change.
+	OnCircuitStateChange func(ctx context.Context, name string, from, to CircuitState)
 }
 
 func (h *Hooks) applyToCall(cfg *callCfg) {
@@ -62,3 +67,13 @@ func (h *Hooks) onMarshalError(ctx context.Context, err error) {
 	}
 	h.OnMarshalError(ctx, err)
 }
+
+func (h *Hooks) onCircuitStateChange(ctx context.Context, name string, from, to CircuitState) {
+	if h == nil {
+		return
+	}
+	if h.OnCircuitStateChange == nil {
+		return
+	}
+	h.OnCircuitStateChange(ctx, name, from, to)
+}
@@ -17,6 +17,7 @@ func TestHooks(t *testing.T) {
 		h.onInternalError(ctx, err)
 		h.onNetworkError(ctx, err)
 		h.onMarshalError(ctx, err)
+		h.onCircuitStateChange(ctx, "foo", CircuitClosed, CircuitOpen)
 	})
 
 	t.Run("zero", func(t *testing.T) {
@@ -24,6 +25,7 @@ func TestHooks(t *testing.T) {
 		h.onInternalError(ctx, err)
 		h.onNetworkError(ctx, err)
 		h.onMarshalError(ctx, err)
+		h.onCircuitStateChange(ctx, "foo", CircuitClosed, CircuitOpen)
 	})
 
 	t.Run("nonzero", func(t *testing.T) {
@@ -35,10 +37,14 @@ func TestHooks(t *testing.T) {
 			OnInternalError: increment,
 			OnNetworkError:  increment,
 			OnMarshalError:  increment,
+			OnCircuitStateChange: func(_ context.Context, _ string, _, _ CircuitState) {
+				calls++
+			},
 		}
 		h.onInternalError(ctx, err)
 		h.onNetworkError(ctx, err)
 		h.onMarshalError(ctx, err)
-		assert.Equal(t, calls, 3, "expected one call per error")
+		h.onCircuitStateChange(ctx, "foo", CircuitClosed, CircuitOpen)
+		assert.Equal(t, calls, 4, "expected one call per hook")
 	})
 }
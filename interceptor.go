@@ -27,16 +27,61 @@ type Interceptor interface {
 	Wrap(Func) Func
 }
 
+// CallStreamFunc is the generic signature of a streaming RPC from the
+// client's perspective: it opens a Stream to the server. StreamInterceptors
+// wrap CallStreamFuncs.
+type CallStreamFunc func(context.Context) (Stream, error)
+
+// HandlerStreamFunc is the generic signature of a streaming RPC from the
+// handler's perspective: it uses the Stream until the RPC is complete.
+// StreamInterceptors wrap HandlerStreamFuncs.
+type HandlerStreamFunc func(context.Context, Stream) error
+
+// A StreamInterceptor is an Interceptor that also wraps streaming RPCs. On
+// clients, it wraps the creation of the Stream; on handlers, it wraps the
+// whole RPC. To act on each message sent or received, interceptors may return
+// (or pass along) a Stream that wraps the original.
+//
+// Chains apply StreamInterceptors to both unary and streaming RPCs, and apply
+// plain Interceptors only to unary RPCs.
+type StreamInterceptor interface {
+	Interceptor
+
+	WrapCallStream(CallStreamFunc) CallStreamFunc
+	WrapHandlerStream(HandlerStreamFunc) HandlerStreamFunc
+}
+
+type shortCircuit struct {
+	err error
+}
+
+var _ StreamInterceptor = (*shortCircuit)(nil)
+
 // ShortCircuit builds an interceptor that doesn't call the wrapped Func.
-// Instead, it returns the supplied Error immediately.
+// Instead, it returns the supplied Error immediately. The returned interceptor
+// short-circuits streaming RPCs too.
 //
 // This is primarily useful when testing error handling. It's also used
 // throughout reRPC's examples to avoid making network requests.
 func ShortCircuit(err error) Interceptor {
-	return InterceptorFunc(func(next Func) Func {
-		return Func(func(_ context.Context, _ proto.Message) (proto.Message, error) {
-			return nil, err
-		})
+	return &shortCircuit{err}
+}
+
+func (sc *shortCircuit) Wrap(next Func) Func {
+	return Func(func(_ context.Context, _ proto.Message) (proto.Message, error) {
+		return nil, sc.err
+	})
+}
+
+func (sc *shortCircuit) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	return CallStreamFunc(func(_ context.Context) (Stream, error) {
+		return nil, sc.err
+	})
+}
+
+func (sc *shortCircuit) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	return HandlerStreamFunc(func(_ context.Context, _ Stream) error {
+		return sc.err
 	})
 }
 
@@ -47,17 +92,21 @@ type InterceptorFunc func(Func) Func
 // Wrap implements Interceptor.
 func (f InterceptorFunc) Wrap(next Func) Func { return f(next) }
 
-// A Chain composes multiple interceptors into one. A Chain is an Interceptor,
-// a CallOption, and a HandlerOption. Note that when used as a CallOption or a
-// HandlerOption, a Chain replaces any previously-configured interceptors.
+// A Chain composes multiple interceptors into one. A Chain is a
+// StreamInterceptor, a CallOption, and a HandlerOption. Note that when used as
+// a CallOption or a HandlerOption, a Chain replaces any previously-configured
+// interceptors.
+//
+// Streaming RPCs skip any interceptors in the Chain that don't implement
+// StreamInterceptor.
 type Chain struct {
 	interceptors []Interceptor
 }
 
 var (
-	_ Interceptor   = (*Chain)(nil)
-	_ CallOption    = (*Chain)(nil)
-	_ HandlerOption = (*Chain)(nil)
+	_ StreamInterceptor = (*Chain)(nil)
+	_ CallOption        = (*Chain)(nil)
+	_ HandlerOption     = (*Chain)(nil)
 )
 
 // NewChain composes multiple interceptors into one. The first interceptor
@@ -79,6 +128,26 @@ func (c *Chain) Wrap(next Func) Func {
 	return next
 }
 
+// WrapCallStream implements StreamInterceptor.
+func (c *Chain) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		if interceptor, ok := c.interceptors[i].(StreamInterceptor); ok {
+			next = interceptor.WrapCallStream(next)
+		}
+	}
+	return next
+}
+
+// WrapHandlerStream implements StreamInterceptor.
+func (c *Chain) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		if interceptor, ok := c.interceptors[i].(StreamInterceptor); ok {
+			next = interceptor.WrapHandlerStream(next)
+		}
+	}
+	return next
+}
+
 func (c *Chain) applyToCall(cfg *callCfg) {
 	cfg.Interceptor = c
 }
@@ -91,7 +160,7 @@ type timeoutClamp struct {
 	min, max time.Duration
 }
 
-var _ Interceptor = (*timeoutClamp)(nil)
+var _ StreamInterceptor = (*timeoutClamp)(nil)
 
 // ClampTimeout sets the minimum and maximum allowable timeouts for clients and
 // handlers.
@@ -106,39 +175,104 @@ var _ Interceptor = (*timeoutClamp)(nil)
 // caps the allowed timeout. Calls with a timeout larger than the max, or calls
 // with no timeout at all, have their timeouts reduced to the maximum allowed
 // value.
+//
+// The returned Interceptor also clamps the timeouts of streaming RPCs. On
+// clients, the clamped timeout applies to the whole stream and is released
+// when the stream's CloseReceive method is called.
+//
+// Twirp requests are clamped too. Handlers read timeouts from the
+// Rerpc-Timeout-Ms or Grpc-Timeout headers before Interceptors run, so Twirp
+// requests without either header have no timeout until ClampTimeout applies
+// the max. Clients send the clamped timeout to the server.
 func ClampTimeout(min, max time.Duration) Interceptor {
 	return &timeoutClamp{min, max}
 }
 
 func (c *timeoutClamp) Wrap(next Func) Func {
 	return Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
-		untilDeadline := time.Duration(math.MaxInt64)
-		if deadline, ok := ctx.Deadline(); ok {
-			untilDeadline = time.Until(deadline)
+		ctx, cancel, err := c.clamp(ctx)
+		if err != nil {
+			return nil, err
 		}
-		if c.max > 0 && untilDeadline > c.max {
-			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(ctx, c.max)
-			defer cancel()
+		defer cancel()
+		return next(ctx, req)
+	})
+}
+
+func (c *timeoutClamp) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	return CallStreamFunc(func(ctx context.Context) (Stream, error) {
+		ctx, cancel, clampErr := c.clamp(ctx)
+		if clampErr != nil {
+			return nil, clampErr
 		}
-		if c.min > 0 && untilDeadline < c.min {
-			return nil, errorf(CodeDeadlineExceeded, "timeout is %v, configured min is %v", untilDeadline, c.min)
+		stream, err := next(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
 		}
-		return next(ctx, req)
+		// The stream outlives this function, so we can't cancel the clamped
+		// context until the caller is done with it.
+		return &cancelOnCloseStream{Stream: stream, cancel: cancel}, nil
 	})
 }
 
+func (c *timeoutClamp) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	return HandlerStreamFunc(func(ctx context.Context, stream Stream) error {
+		ctx, cancel, err := c.clamp(ctx)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return next(ctx, stream)
+	})
+}
+
+// clamp applies the configured limits to the context's deadline. Callers must
+// call the returned CancelFunc unless clamp returns an error.
+func (c *timeoutClamp) clamp(ctx context.Context) (context.Context, context.CancelFunc, *Error) {
+	untilDeadline := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		untilDeadline = time.Until(deadline)
+	}
+	if c.min > 0 && untilDeadline < c.min {
+		return nil, nil, errorf(CodeDeadlineExceeded, "timeout is %v, configured min is %v", untilDeadline, c.min)
+	}
+	if c.max > 0 && untilDeadline > c.max {
+		ctx, cancel := context.WithTimeout(ctx, c.max)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// cancelOnCloseStream releases a context's resources when the stream is
+// closed.
+type cancelOnCloseStream struct {
+	Stream
+
+	cancel context.CancelFunc
+}
+
+func (s *cancelOnCloseStream) CloseReceive() error {
+	err := s.Stream.CloseReceive()
+	s.cancel()
+	return err
+}
+
 type recovery struct {
 	Log func(context.Context, interface{})
 }
 
-var _ Interceptor = (*recovery)(nil)
+var _ StreamInterceptor = (*recovery)(nil)
 
 // Recover wraps clients and handlers to recover from panics. It uses the
 // supplied function to log the recovered value. The log function must be
 // non-nil and safe to call concurrently. Keep in mind that panics initiated in
 // other goroutines will still crash the process!
 //
+// The returned Interceptor also recovers from panics in streaming RPCs: on
+// clients, while opening the stream, and on handlers, anywhere in the RPC.
+// Recovered streaming RPCs return CodeInternal.
+//
 // When composed with other Interceptors in a Chain, Recover should be the
 // outermost Interceptor.
 func Recover(log func(context.Context, interface{})) Interceptor {
@@ -152,6 +286,30 @@ func (r *recovery) Wrap(next Func) Func {
 	})
 }
 
+func (r *recovery) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	return CallStreamFunc(func(ctx context.Context) (stream Stream, err error) {
+		defer func() {
+			if val := recover(); val != nil {
+				r.Log(ctx, val)
+				stream, err = nil, errorf(CodeInternal, "panic while opening stream")
+			}
+		}()
+		return next(ctx)
+	})
+}
+
+func (r *recovery) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	return HandlerStreamFunc(func(ctx context.Context, stream Stream) (err error) {
+		defer func() {
+			if val := recover(); val != nil {
+				r.Log(ctx, val)
+				err = errorf(CodeInternal, "panic in handler")
+			}
+		}()
+		return next(ctx, stream)
+	})
+}
+
 func (r *recovery) recoverAndLog(ctx context.Context) {
 	if val := recover(); val != nil {
 		r.Log(ctx, val)
@@ -33,6 +33,38 @@ func (i *loggingInterceptor) Wrap(next Func) Func {
 	})
 }
 
+type loggingStreamInterceptor struct {
+	loggingInterceptor
+}
+
+func (i *loggingStreamInterceptor) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	return CallStreamFunc(func(ctx context.Context) (Stream, error) {
+		io.WriteString(i.w, i.before)
+		defer func() { io.WriteString(i.w, i.after) }()
+		return next(ctx)
+	})
+}
+
+func (i *loggingStreamInterceptor) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	return HandlerStreamFunc(func(ctx context.Context, stream Stream) error {
+		io.WriteString(i.w, i.before)
+		defer func() { io.WriteString(i.w, i.after) }()
+		return next(ctx, stream)
+	})
+}
+
+// nopStream is a Stream that doesn't send or receive any messages.
+type nopStream struct {
+	ctx    context.Context
+	closed bool
+}
+
+func (s *nopStream) Context() context.Context    { return s.ctx }
+func (s *nopStream) Send(proto.Message) error    { return nil }
+func (s *nopStream) CloseSend(error) error       { return nil }
+func (s *nopStream) Receive(proto.Message) error { return io.EOF }
+func (s *nopStream) CloseReceive() error         { s.closed = true; return nil }
+
 func TestChain(t *testing.T) {
 	out := &bytes.Buffer{}
 	chain := NewChain(
@@ -51,6 +83,60 @@ func TestChain(t *testing.T) {
 	assert.True(t, called, "original Func called")
 }
 
+func TestChainStream(t *testing.T) {
+	out := &bytes.Buffer{}
+	chain := NewChain(
+		&loggingStreamInterceptor{loggingInterceptor{out, "b1.", "a1"}},
+		&loggingInterceptor{out, "skipped.", "skipped."},
+		&loggingStreamInterceptor{loggingInterceptor{out, "b2.", "a2."}},
+	)
+	const onion = "b1.b2.a2.a1" // expected execution order
+	t.Run("call", func(t *testing.T) {
+		out.Reset()
+		var called bool
+		open := chain.WrapCallStream(CallStreamFunc(func(ctx context.Context) (Stream, error) {
+			called = true
+			return &nopStream{ctx: ctx}, nil
+		}))
+		stream, err := open(context.Background())
+		assert.Nil(t, err, "returned error")
+		assert.NotNil(t, stream, "returned stream")
+		assert.Equal(t, out.String(), onion, "execution onion")
+		assert.True(t, called, "original CallStreamFunc called")
+	})
+	t.Run("handler", func(t *testing.T) {
+		out.Reset()
+		var called bool
+		serve := chain.WrapHandlerStream(HandlerStreamFunc(func(context.Context, Stream) error {
+			called = true
+			return nil
+		}))
+		err := serve(context.Background(), &nopStream{ctx: context.Background()})
+		assert.Nil(t, err, "returned error")
+		assert.Equal(t, out.String(), onion, "execution onion")
+		assert.True(t, called, "original HandlerStreamFunc called")
+	})
+}
+
+func TestShortCircuitStream(t *testing.T) {
+	short := ShortCircuit(errorf(CodeUnimplemented, "short-circuited")).(StreamInterceptor)
+	var called bool
+	open := short.WrapCallStream(CallStreamFunc(func(ctx context.Context) (Stream, error) {
+		called = true
+		return &nopStream{ctx: ctx}, nil
+	}))
+	stream, err := open(context.Background())
+	assert.Nil(t, stream, "returned stream")
+	assert.Equal(t, CodeOf(err), CodeUnimplemented, "call error code")
+	serve := short.WrapHandlerStream(HandlerStreamFunc(func(context.Context, Stream) error {
+		called = true
+		return nil
+	}))
+	err = serve(context.Background(), &nopStream{ctx: context.Background()})
+	assert.Equal(t, CodeOf(err), CodeUnimplemented, "handler error code")
+	assert.False(t, called, "original func called")
+}
+
 func TestClampTimeout(t *testing.T) {
 	const min, max = time.Second, 10 * time.Second
 	clamp := ClampTimeout(min, max)
@@ -92,6 +178,42 @@ func TestClampTimeout(t *testing.T) {
 		assert.NotNil(t, res, "unbounded func result")
 		assert.True(t, called, "unbounded func called")
 	})
+	t.Run("handler_stream", func(t *testing.T) {
+		stream := &nopStream{ctx: context.Background()}
+		var called bool
+		serve := clamp.(StreamInterceptor).WrapHandlerStream(HandlerStreamFunc(func(ctx context.Context, _ Stream) error {
+			called = true
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok, "context has deadline")
+			assert.True(t, time.Until(deadline) <= max, "timeout clamped to max")
+			return nil
+		}))
+		assert.Nil(t, serve(context.Background(), stream), "unbounded stream error")
+		assert.True(t, called, "unbounded stream called")
+
+		called = false
+		ctx, cancel := context.WithTimeout(context.Background(), min-1)
+		defer cancel()
+		err := serve(ctx, stream)
+		assert.Equal(t, CodeOf(err), CodeDeadlineExceeded, "short stream error code")
+		assert.False(t, called, "short stream called")
+	})
+	t.Run("call_stream", func(t *testing.T) {
+		var inner *nopStream
+		open := clamp.(StreamInterceptor).WrapCallStream(CallStreamFunc(func(ctx context.Context) (Stream, error) {
+			inner = &nopStream{ctx: ctx}
+			return inner, nil
+		}))
+		stream, err := open(context.Background())
+		assert.Nil(t, err, "open error")
+		deadline, ok := inner.Context().Deadline()
+		assert.True(t, ok, "context has deadline")
+		assert.True(t, time.Until(deadline) <= max, "timeout clamped to max")
+		assert.Nil(t, inner.Context().Err(), "context error before close")
+		assert.Nil(t, stream.CloseReceive(), "close receive")
+		assert.True(t, inner.closed, "inner stream closed")
+		assert.ErrorIs(t, inner.Context().Err(), context.Canceled, "context error after close")
+	})
 }
 
 func TestRecover(t *testing.T) {
@@ -111,4 +233,21 @@ func TestRecover(t *testing.T) {
 	assert.True(t, called, "logged panic")
 	assert.Nil(t, err, "error")
 	assert.Nil(t, res, "result")
+
+	called = false
+	open := r.(StreamInterceptor).WrapCallStream(CallStreamFunc(func(context.Context) (Stream, error) {
+		panic(msg)
+	}))
+	stream, err := open(context.Background())
+	assert.True(t, called, "logged call stream panic")
+	assert.Nil(t, stream, "call stream")
+	assert.Equal(t, CodeOf(err), CodeInternal, "call stream error code")
+
+	called = false
+	serve := r.(StreamInterceptor).WrapHandlerStream(HandlerStreamFunc(func(context.Context, Stream) error {
+		panic(msg)
+	}))
+	err = serve(context.Background(), &nopStream{ctx: context.Background()})
+	assert.True(t, called, "logged handler stream panic")
+	assert.Equal(t, CodeOf(err), CodeInternal, "handler stream error code")
 }
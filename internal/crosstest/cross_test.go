@@ -228,7 +228,10 @@ func TestReRPCServer(t *testing.T) {
 	reg := rerpc.NewRegistrar()
 	mux := http.NewServeMux()
 	mux.Handle(crosspb.NewCrossServiceHandlerReRPC(crossServer{}, reg))
-	mux.Handle(rerpc.NewReflectionHandler(reg))
+	paths, reflection := rerpc.NewReflectionHandler(reg)
+	for _, path := range paths {
+		mux.Handle(path, reflection)
+	}
 	server := httptest.NewUnstartedServer(mux)
 	server.EnableHTTP2 = true
 	server.StartTLS()
@@ -0,0 +1,161 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.17.3
+// source: internal/errdetails/v1/errdetails.proto
+
+// This package is for internal use by reRPC, and provides no
+// backward compatibility guarantees whatsoever.
+
+package errdetailspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Describes when clients can retry a failed request.
+//
+// This struct must remain binary-compatible with RetryInfo in
+// https://github.com/googleapis/googleapis/blob/master/google/rpc/error_details.proto.
+type RetryInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RetryDelay *durationpb.Duration `protobuf:"bytes,1,opt,name=retry_delay,json=retryDelay,proto3" json:"retry_delay,omitempty"`
+}
+
+func (x *RetryInfo) Reset() {
+	*x = RetryInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_errdetails_v1_errdetails_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RetryInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryInfo) ProtoMessage() {}
+
+func (x *RetryInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_errdetails_v1_errdetails_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryInfo.ProtoReflect.Descriptor instead.
+func (*RetryInfo) Descriptor() ([]byte, []int) {
+	return file_internal_errdetails_v1_errdetails_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RetryInfo) GetRetryDelay() *durationpb.Duration {
+	if x != nil {
+		return x.RetryDelay
+	}
+	return nil
+}
+
+var File_internal_errdetails_v1_errdetails_proto protoreflect.FileDescriptor
+
+var file_internal_errdetails_v1_errdetails_proto_rawDesc = []byte{
+	0x0a, 0x27, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x65, 0x72, 0x72, 0x64, 0x65,
+	0x74, 0x61, 0x69, 0x6c, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61,
+	0x69, 0x6c, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x6e, 0x61, 0x6c, 0x2e, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x2e, 0x76,
+	0x31, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x22, 0x47, 0x0a, 0x09, 0x52, 0x65, 0x74, 0x72, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3a,
+	0x0a, 0x0b, 0x72, 0x65, 0x74, 0x72, 0x79, 0x5f, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a,
+	0x72, 0x65, 0x74, 0x72, 0x79, 0x44, 0x65, 0x6c, 0x61, 0x79, 0x42, 0x3c, 0x5a, 0x3a, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x72, 0x65, 0x72, 0x70, 0x63, 0x2f, 0x72,
+	0x65, 0x72, 0x70, 0x63, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x65, 0x72,
+	0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x65, 0x72, 0x72, 0x64,
+	0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_internal_errdetails_v1_errdetails_proto_rawDescOnce sync.Once
+	file_internal_errdetails_v1_errdetails_proto_rawDescData = file_internal_errdetails_v1_errdetails_proto_rawDesc
+)
+
+func file_internal_errdetails_v1_errdetails_proto_rawDescGZIP() []byte {
+	file_internal_errdetails_v1_errdetails_proto_rawDescOnce.Do(func() {
+		file_internal_errdetails_v1_errdetails_proto_rawDescData = protoimpl.X.CompressGZIP(file_internal_errdetails_v1_errdetails_proto_rawDescData)
+	})
+	return file_internal_errdetails_v1_errdetails_proto_rawDescData
+}
+
+var file_internal_errdetails_v1_errdetails_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_internal_errdetails_v1_errdetails_proto_goTypes = []interface{}{
+	(*RetryInfo)(nil),           // 0: internal.errdetails.v1.RetryInfo
+	(*durationpb.Duration)(nil), // 1: google.protobuf.Duration
+}
+var file_internal_errdetails_v1_errdetails_proto_depIdxs = []int32{
+	1, // 0: internal.errdetails.v1.RetryInfo.retry_delay:type_name -> google.protobuf.Duration
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_internal_errdetails_v1_errdetails_proto_init() }
+func file_internal_errdetails_v1_errdetails_proto_init() {
+	if File_internal_errdetails_v1_errdetails_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_internal_errdetails_v1_errdetails_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RetryInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_internal_errdetails_v1_errdetails_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_errdetails_v1_errdetails_proto_goTypes,
+		DependencyIndexes: file_internal_errdetails_v1_errdetails_proto_depIdxs,
+		MessageInfos:      file_internal_errdetails_v1_errdetails_proto_msgTypes,
+	}.Build()
+	File_internal_errdetails_v1_errdetails_proto = out.File
+	file_internal_errdetails_v1_errdetails_proto_rawDesc = nil
+	file_internal_errdetails_v1_errdetails_proto_goTypes = nil
+	file_internal_errdetails_v1_errdetails_proto_depIdxs = nil
+}
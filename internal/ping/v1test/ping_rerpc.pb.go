@@ -8,8 +8,10 @@ package pingpb
 
 import (
 	context "context"
+	errors "errors"
 	rerpc "github.com/rerpc/rerpc"
 	proto "google.golang.org/protobuf/proto"
+	io "io"
 	http "net/http"
 	strings "strings"
 )
@@ -27,11 +29,17 @@ const _ = rerpc.SupportsCodeGenV0 // requires reRPC v0.0.1 or later
 type PingServiceClientReRPC interface {
 	Ping(ctx context.Context, req *PingRequest, opts ...rerpc.CallOption) (*PingResponse, error)
 	Fail(ctx context.Context, req *FailRequest, opts ...rerpc.CallOption) (*FailResponse, error)
+	Sum(ctx context.Context, opts ...rerpc.CallOption) (*PingService_SumClientReRPC, error)
+	CountUp(ctx context.Context, req *CountUpRequest, opts ...rerpc.CallOption) (*PingService_CountUpClientReRPC, error)
+	CumSum(ctx context.Context, opts ...rerpc.CallOption) (*PingService_CumSumClientReRPC, error)
 }
 
 type pingServiceClientReRPC struct {
-	ping rerpc.Client
-	fail rerpc.Client
+	ping    rerpc.Client
+	fail    rerpc.Client
+	sum     rerpc.Client
+	countUp rerpc.Client
+	cumSum  rerpc.Client
 }
 
 // NewPingServiceClientReRPC constructs a client for the
@@ -61,6 +69,33 @@ func NewPingServiceClientReRPC(baseURL string, doer rerpc.Doer, opts ...rerpc.Ca
 			func() proto.Message { return &FailResponse{} },  // response constructor
 			opts...,
 		),
+		sum: *rerpc.NewStreamingClient(
+			rerpc.StreamTypeClient,
+			doer,
+			baseURL+"/internal.ping.v1test.PingService/Sum", // complete URL to call method
+			"internal.ping.v1test.PingService.Sum",          // fully-qualified protobuf method
+			"internal.ping.v1test.PingService",              // fully-qualified protobuf service
+			"internal.ping.v1test",                          // fully-qualified protobuf package
+			opts...,
+		),
+		countUp: *rerpc.NewStreamingClient(
+			rerpc.StreamTypeServer,
+			doer,
+			baseURL+"/internal.ping.v1test.PingService/CountUp", // complete URL to call method
+			"internal.ping.v1test.PingService.CountUp",          // fully-qualified protobuf method
+			"internal.ping.v1test.PingService",                  // fully-qualified protobuf service
+			"internal.ping.v1test",                              // fully-qualified protobuf package
+			opts...,
+		),
+		cumSum: *rerpc.NewStreamingClient(
+			rerpc.StreamTypeBidirectional,
+			doer,
+			baseURL+"/internal.ping.v1test.PingService/CumSum", // complete URL to call method
+			"internal.ping.v1test.PingService.CumSum",          // fully-qualified protobuf method
+			"internal.ping.v1test.PingService",                 // fully-qualified protobuf service
+			"internal.ping.v1test",                             // fully-qualified protobuf package
+			opts...,
+		),
 	}
 }
 
@@ -84,6 +119,135 @@ func (c *pingServiceClientReRPC) Fail(ctx context.Context, req *FailRequest, opt
 	return res.(*FailResponse), nil
 }
 
+// Sum calls internal.ping.v1test.PingService.Sum. Call options passed here
+// apply only to this call.
+func (c *pingServiceClientReRPC) Sum(ctx context.Context, opts ...rerpc.CallOption) (*PingService_SumClientReRPC, error) {
+	stream, err := c.sum.Stream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &PingService_SumClientReRPC{stream: stream}, nil
+}
+
+// CountUp calls internal.ping.v1test.PingService.CountUp. Call options passed
+// here apply only to this call.
+func (c *pingServiceClientReRPC) CountUp(ctx context.Context, req *CountUpRequest, opts ...rerpc.CallOption) (*PingService_CountUpClientReRPC, error) {
+	stream, err := c.countUp.Stream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// If the server has already closed the stream, Send returns an error wrapping
+	// io.EOF. The server's status is available from Receive.
+	if err := stream.Send(req); err != nil && !errors.Is(err, io.EOF) {
+		_ = stream.CloseSend(err)
+		_ = stream.CloseReceive()
+		return nil, err
+	}
+	if err := stream.CloseSend(nil); err != nil {
+		_ = stream.CloseReceive()
+		return nil, err
+	}
+	return &PingService_CountUpClientReRPC{stream: stream}, nil
+}
+
+// CumSum calls internal.ping.v1test.PingService.CumSum. Call options passed
+// here apply only to this call.
+func (c *pingServiceClientReRPC) CumSum(ctx context.Context, opts ...rerpc.CallOption) (*PingService_CumSumClientReRPC, error) {
+	stream, err := c.cumSum.Stream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &PingService_CumSumClientReRPC{stream: stream}, nil
+}
+
+// PingService_SumClientReRPC is the client-side stream for the
+// internal.ping.v1test.PingService.Sum procedure.
+type PingService_SumClientReRPC struct {
+	stream rerpc.Stream
+}
+
+// Send a message to the server. If the server has already closed the stream,
+// Send returns an error wrapping io.EOF; call CloseAndReceive to see the
+// server's status.
+func (s *PingService_SumClientReRPC) Send(msg *SumRequest) error {
+	return s.stream.Send(msg)
+}
+
+// CloseAndReceive closes the send side of the stream and waits for the server's
+// response.
+func (s *PingService_SumClientReRPC) CloseAndReceive() (*SumResponse, error) {
+	if err := s.stream.CloseSend(nil); err != nil {
+		_ = s.stream.CloseReceive()
+		return nil, err
+	}
+	res := &SumResponse{}
+	if err := s.stream.Receive(res); err != nil {
+		_ = s.stream.CloseReceive()
+		return nil, err
+	}
+	if err := s.stream.CloseReceive(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PingService_CountUpClientReRPC is the client-side stream for the
+// internal.ping.v1test.PingService.CountUp procedure.
+type PingService_CountUpClientReRPC struct {
+	stream rerpc.Stream
+}
+
+// Receive a message. When the server is done sending messages and no errors
+// have occurred, Receive returns an error wrapping io.EOF.
+func (s *PingService_CountUpClientReRPC) Receive() (*CountUpResponse, error) {
+	res := &CountUpResponse{}
+	if err := s.stream.Receive(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Close the stream. Callers must close the stream, even if they've received all
+// the server's messages.
+func (s *PingService_CountUpClientReRPC) Close() error {
+	return s.stream.CloseReceive()
+}
+
+// PingService_CumSumClientReRPC is the client-side stream for the
+// internal.ping.v1test.PingService.CumSum procedure.
+type PingService_CumSumClientReRPC struct {
+	stream rerpc.Stream
+}
+
+// Send a message to the server. If the server has already closed the stream,
+// Send returns an error wrapping io.EOF; call Receive to see the server's
+// status.
+func (s *PingService_CumSumClientReRPC) Send(msg *CumSumRequest) error {
+	return s.stream.Send(msg)
+}
+
+// CloseSend closes the send side of the stream, telling the server that no more
+// messages are coming.
+func (s *PingService_CumSumClientReRPC) CloseSend() error {
+	return s.stream.CloseSend(nil)
+}
+
+// Receive a message. When the server is done sending messages and no errors
+// have occurred, Receive returns an error wrapping io.EOF.
+func (s *PingService_CumSumClientReRPC) Receive() (*CumSumResponse, error) {
+	res := &CumSumResponse{}
+	if err := s.stream.Receive(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CloseReceive closes the receive side of the stream. Callers must close both
+// sides of the stream, even if they've received all the server's messages.
+func (s *PingService_CumSumClientReRPC) CloseReceive() error {
+	return s.stream.CloseReceive()
+}
+
 // PingServiceReRPC is a server for the internal.ping.v1test.PingService
 // service. To make sure that adding methods to this protobuf service doesn't
 // break all implementations of this interface, all implementations must embed
@@ -95,6 +259,9 @@ func (c *pingServiceClientReRPC) Fail(ctx context.Context, req *FailRequest, opt
 type PingServiceReRPC interface {
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	Fail(context.Context, *FailRequest) (*FailResponse, error)
+	Sum(context.Context, *PingService_SumServerReRPC) (*SumResponse, error)
+	CountUp(context.Context, *CountUpRequest, *PingService_CountUpServerReRPC) error
+	CumSum(context.Context, *PingService_CumSumServerReRPC) error
 	mustEmbedUnimplementedPingServiceReRPC()
 }
 
@@ -145,6 +312,59 @@ func NewPingServiceHandlerReRPC(svc PingServiceReRPC, opts ...rerpc.HandlerOptio
 		fail.Serve(w, r, &FailRequest{})
 	})
 
+	sum := rerpc.NewStreamingHandler(
+		rerpc.StreamTypeClient,
+		"internal.ping.v1test.PingService.Sum", // fully-qualified protobuf method
+		"internal.ping.v1test.PingService",     // fully-qualified protobuf service
+		"internal.ping.v1test",                 // fully-qualified protobuf package
+		func(ctx context.Context, stream rerpc.Stream) error {
+			res, err := svc.Sum(ctx, &PingService_SumServerReRPC{stream: stream})
+			if err != nil {
+				return err
+			}
+			return stream.Send(res)
+		},
+		opts...,
+	)
+	mux.HandleFunc("/internal.ping.v1test.PingService/Sum", func(w http.ResponseWriter, r *http.Request) {
+		sum.Serve(w, r, nil /* streams construct their own messages */)
+	})
+
+	countUp := rerpc.NewStreamingHandler(
+		rerpc.StreamTypeServer,
+		"internal.ping.v1test.PingService.CountUp", // fully-qualified protobuf method
+		"internal.ping.v1test.PingService",         // fully-qualified protobuf service
+		"internal.ping.v1test",                     // fully-qualified protobuf package
+		func(ctx context.Context, stream rerpc.Stream) error {
+			req := &CountUpRequest{}
+			if err := stream.Receive(req); err != nil {
+				return err
+			}
+			if err := stream.CloseReceive(); err != nil {
+				return err
+			}
+			return svc.CountUp(ctx, req, &PingService_CountUpServerReRPC{stream: stream})
+		},
+		opts...,
+	)
+	mux.HandleFunc("/internal.ping.v1test.PingService/CountUp", func(w http.ResponseWriter, r *http.Request) {
+		countUp.Serve(w, r, nil /* streams construct their own messages */)
+	})
+
+	cumSum := rerpc.NewStreamingHandler(
+		rerpc.StreamTypeBidirectional,
+		"internal.ping.v1test.PingService.CumSum", // fully-qualified protobuf method
+		"internal.ping.v1test.PingService",        // fully-qualified protobuf service
+		"internal.ping.v1test",                    // fully-qualified protobuf package
+		func(ctx context.Context, stream rerpc.Stream) error {
+			return svc.CumSum(ctx, &PingService_CumSumServerReRPC{stream: stream})
+		},
+		opts...,
+	)
+	mux.HandleFunc("/internal.ping.v1test.PingService/CumSum", func(w http.ResponseWriter, r *http.Request) {
+		cumSum.Serve(w, r, nil /* streams construct their own messages */)
+	})
+
 	// Respond to unknown protobuf methods with gRPC and Twirp's 404 equivalents.
 	mux.Handle("/", rerpc.NewBadRouteHandler(opts...))
 
@@ -166,4 +386,66 @@ func (UnimplementedPingServiceReRPC) Fail(context.Context, *FailRequest) (*FailR
 	return nil, rerpc.Errorf(rerpc.CodeUnimplemented, "internal.ping.v1test.PingService.Fail isn't implemented")
 }
 
+func (UnimplementedPingServiceReRPC) Sum(context.Context, *PingService_SumServerReRPC) (*SumResponse, error) {
+	return nil, rerpc.Errorf(rerpc.CodeUnimplemented, "internal.ping.v1test.PingService.Sum isn't implemented")
+}
+
+func (UnimplementedPingServiceReRPC) CountUp(context.Context, *CountUpRequest, *PingService_CountUpServerReRPC) error {
+	return rerpc.Errorf(rerpc.CodeUnimplemented, "internal.ping.v1test.PingService.CountUp isn't implemented")
+}
+
+func (UnimplementedPingServiceReRPC) CumSum(context.Context, *PingService_CumSumServerReRPC) error {
+	return rerpc.Errorf(rerpc.CodeUnimplemented, "internal.ping.v1test.PingService.CumSum isn't implemented")
+}
+
 func (UnimplementedPingServiceReRPC) mustEmbedUnimplementedPingServiceReRPC() {}
+
+// PingService_SumServerReRPC is the server-side stream for the
+// internal.ping.v1test.PingService.Sum procedure.
+type PingService_SumServerReRPC struct {
+	stream rerpc.Stream
+}
+
+// Receive a message. When the client is done sending messages, Receive returns
+// an error wrapping io.EOF.
+func (s *PingService_SumServerReRPC) Receive() (*SumRequest, error) {
+	req := &SumRequest{}
+	if err := s.stream.Receive(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// PingService_CountUpServerReRPC is the server-side stream for the
+// internal.ping.v1test.PingService.CountUp procedure.
+type PingService_CountUpServerReRPC struct {
+	stream rerpc.Stream
+}
+
+// Send a message to the client. To send an error instead, return it from the
+// service implementation.
+func (s *PingService_CountUpServerReRPC) Send(msg *CountUpResponse) error {
+	return s.stream.Send(msg)
+}
+
+// PingService_CumSumServerReRPC is the server-side stream for the
+// internal.ping.v1test.PingService.CumSum procedure.
+type PingService_CumSumServerReRPC struct {
+	stream rerpc.Stream
+}
+
+// Receive a message. When the client is done sending messages, Receive returns
+// an error wrapping io.EOF.
+func (s *PingService_CumSumServerReRPC) Receive() (*CumSumRequest, error) {
+	req := &CumSumRequest{}
+	if err := s.stream.Receive(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Send a message to the client. To send an error instead, return it from the
+// service implementation.
+func (s *PingService_CumSumServerReRPC) Send(msg *CumSumResponse) error {
+	return s.stream.Send(msg)
+}
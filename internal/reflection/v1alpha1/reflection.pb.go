@@ -8,8 +8,9 @@
 // compatibility guarantees whatsoever.
 //
 // The types here must remain wire-compatible with the types in
-// grpc.reflection.v1alpha:
+// grpc.reflection.v1alpha and grpc.reflection.v1, which are identical:
 //   https://github.com/grpc/grpc/blob/master/src/proto/grpc/reflection/v1alpha/reflection.proto
+//   https://github.com/grpc/grpc/blob/master/src/proto/grpc/reflection/v1/reflection.proto
 
 package reflectionpb
 
@@ -0,0 +1,206 @@
+package rerpc
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults for the zero values of ConcurrencyLimiter's fields.
+const (
+	defaultConcurrencyLimit    = 100
+	defaultConcurrencyMinLimit = 1
+	defaultConcurrencyMaxLimit = 1000
+	defaultConcurrencyTimeout  = 5 * time.Second
+)
+
+// Tuning for the adaptive algorithms.
+const (
+	aimdBackoffRatio  = 0.9  // multiplies the limit after a slow or timed-out call
+	gradientSmoothing = 0.2  // weight of each new estimate
+	gradientLongDecay = 0.01 // weight of each call in the long-term latency average
+	gradientMin       = 0.5  // largest single-call reduction
+)
+
+// A LimitAlgorithm decides how a ConcurrencyLimiter sets its limit.
+type LimitAlgorithm uint8
+
+const (
+	LimitFixed    LimitAlgorithm = iota // never change the limit
+	LimitAIMD                           // additive increase, multiplicative decrease
+	LimitGradient                       // follow changes in latency
+)
+
+// A ConcurrencyLimiter sheds load by capping the number of requests a server
+// handles at once, either per method or per service. Requests over the limit
+// fail immediately with CodeResourceExhausted (HTTP 429 for Twirp), before
+// the server reads their bodies, so an overloaded server degrades instead of
+// exhausting its memory. Interceptors still see rejected requests.
+//
+// The limit may be fixed, or it may adapt to the server's observed latency:
+//
+//   - LimitAIMD raises the limit by one after each call that finishes within
+//     Timeout, and lowers it by 10% after each call that takes longer or
+//     exceeds its deadline.
+//   - LimitGradient compares each call's latency with the long-term average,
+//     lowering the limit as latency rises and probing for a higher limit while
+//     latency is stable.
+//
+// Adaptive limits only grow while at least half the current limit is in use,
+// so a lightly-loaded server doesn't accumulate an unreasonably high limit.
+//
+// ConcurrencyLimiters are valid HandlerOptions. Because they're stateful,
+// share a ConcurrencyLimiter between handlers to share limits: for example,
+// pass the same PerService limiter to all the handlers for a service. A
+// ConcurrencyLimiter's fields must not be modified after its first use.
+type ConcurrencyLimiter struct {
+	// PerService limits each service as a whole, rather than each method
+	// separately.
+	PerService bool
+	// Algorithm chooses between a fixed and an adaptive limit. The default is
+	// LimitFixed.
+	Algorithm LimitAlgorithm
+	// Limit is the maximum number of concurrent requests for fixed limits,
+	// and the initial limit for adaptive limits. If zero, ConcurrencyLimiters
+	// use 100.
+	Limit int
+	// MinLimit and MaxLimit bound adaptive limits. If zero,
+	// ConcurrencyLimiters use 1 and 1000, respectively.
+	MinLimit int
+	MaxLimit int
+	// Timeout is the latency that LimitAIMD treats as a sign of overload. If
+	// zero, ConcurrencyLimiters use 5 seconds.
+	Timeout time.Duration
+
+	now func() time.Time // for tests
+
+	mu     sync.Mutex
+	limits map[string]*concurrencyLimit
+}
+
+func (l *ConcurrencyLimiter) applyToHandler(cfg *handlerCfg) {
+	cfg.ConcurrencyLimiter = l
+}
+
+// concurrencyLimit is the state for a single method or service.
+type concurrencyLimit struct {
+	inflight    int
+	limit       float64
+	longLatency float64 // average latency in nanoseconds, for LimitGradient
+}
+
+// acquire reserves a slot for a request. If it returns a nil error, the caller
+// must call the returned function once the request finishes, reporting
+// whether the request exceeded its deadline.
+func (l *ConcurrencyLimiter) acquire(spec *Specification) (func(timedOut bool), *Error) {
+	name := spec.Method
+	if l.PerService {
+		name = spec.Service
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limits == nil {
+		l.limits = make(map[string]*concurrencyLimit)
+	}
+	c, ok := l.limits[name]
+	if !ok {
+		c = &concurrencyLimit{limit: float64(l.initialLimit())}
+		l.limits[name] = c
+	}
+	if max := c.current(); c.inflight >= max {
+		return nil, errorf(
+			CodeResourceExhausted,
+			"too many concurrent requests for %s: limit is %d",
+			name, max,
+		)
+	}
+	c.inflight++
+	start := l.clock()
+	return func(timedOut bool) {
+		latency := l.clock().Sub(start)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// Check utilization before this request leaves.
+		saturated := c.inflight*2 >= c.current()
+		c.inflight--
+		l.adapt(c, latency, timedOut, saturated)
+	}, nil
+}
+
+// adapt updates an adaptive limit after a request finishes. Callers must hold
+// the lock.
+func (l *ConcurrencyLimiter) adapt(c *concurrencyLimit, latency time.Duration, timedOut, saturated bool) {
+	limit := c.limit
+	switch l.Algorithm {
+	case LimitAIMD:
+		if timedOut || latency > l.timeout() {
+			limit *= aimdBackoffRatio
+		} else if saturated {
+			limit++
+		}
+	case LimitGradient:
+		sample := float64(latency)
+		if sample <= 0 {
+			sample = 1
+		}
+		if c.longLatency == 0 {
+			c.longLatency = sample
+		} else {
+			c.longLatency += gradientLongDecay * (sample - c.longLatency)
+		}
+		gradient := math.Max(gradientMin, math.Min(1, c.longLatency/sample))
+		if timedOut {
+			gradient = gradientMin
+		}
+		estimate := c.limit*gradient + math.Sqrt(c.limit)
+		if estimate > c.limit && !saturated {
+			return
+		}
+		limit = (1-gradientSmoothing)*c.limit + gradientSmoothing*estimate
+	default:
+		return
+	}
+	c.limit = math.Max(float64(l.minLimit()), math.Min(float64(l.maxLimit()), limit))
+}
+
+func (c *concurrencyLimit) current() int {
+	if c.limit < 1 {
+		return 1
+	}
+	return int(c.limit)
+}
+
+func (l *ConcurrencyLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func (l *ConcurrencyLimiter) initialLimit() int {
+	if l.Limit <= 0 {
+		return defaultConcurrencyLimit
+	}
+	return l.Limit
+}
+
+func (l *ConcurrencyLimiter) minLimit() int {
+	if l.MinLimit <= 0 {
+		return defaultConcurrencyMinLimit
+	}
+	return l.MinLimit
+}
+
+func (l *ConcurrencyLimiter) maxLimit() int {
+	if l.MaxLimit <= 0 {
+		return defaultConcurrencyMaxLimit
+	}
+	return l.MaxLimit
+}
+
+func (l *ConcurrencyLimiter) timeout() time.Duration {
+	if l.Timeout <= 0 {
+		return defaultConcurrencyTimeout
+	}
+	return l.Timeout
+}
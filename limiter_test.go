@@ -0,0 +1,113 @@
+package rerpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	ping := &Specification{Method: "acme.ping.v1.PingService.Ping", Service: "acme.ping.v1.PingService"}
+	sum := &Specification{Method: "acme.ping.v1.PingService.Sum", Service: "acme.ping.v1.PingService"}
+
+	newLimiter := func(l *ConcurrencyLimiter) *time.Time {
+		now := time.Unix(0, 0)
+		l.now = func() time.Time { return now }
+		return &now
+	}
+	// fill acquires slots until the limiter rejects a request, returning the
+	// release functions.
+	fill := func(t testing.TB, l *ConcurrencyLimiter, spec *Specification) []func(bool) {
+		t.Helper()
+		var releases []func(bool)
+		for {
+			release, err := l.acquire(spec)
+			if err != nil {
+				assert.Equal(t, err.Code(), CodeResourceExhausted, "error code")
+				return releases
+			}
+			releases = append(releases, release)
+		}
+	}
+	limit := func(l *ConcurrencyLimiter, spec *Specification) int {
+		return l.limits[spec.Method].current()
+	}
+
+	t.Run("fixed", func(t *testing.T) {
+		l := &ConcurrencyLimiter{Limit: 2}
+		releases := fill(t, l, ping)
+		assert.Equal(t, len(releases), 2, "slots")
+		_, err := l.acquire(ping)
+		assert.Match(t, err.Error(), `too many concurrent requests for .*\.Ping: limit is 2`, "message")
+		assert.Equal(t, len(fill(t, l, sum)), 2, "methods limited separately")
+		releases[0](true)
+		assert.Equal(t, len(fill(t, l, ping)), 1, "released slot")
+	})
+
+	t.Run("per_service", func(t *testing.T) {
+		l := &ConcurrencyLimiter{Limit: 2, PerService: true}
+		assert.Equal(t, len(fill(t, l, ping)), 2, "slots")
+		assert.Zero(t, len(fill(t, l, sum)), "service limited as a whole")
+	})
+
+	t.Run("aimd", func(t *testing.T) {
+		l := &ConcurrencyLimiter{Algorithm: LimitAIMD, Limit: 10, Timeout: time.Second}
+		now := newLimiter(l)
+		releases := fill(t, l, ping)
+		for _, release := range releases {
+			release(false)
+		}
+		assert.Equal(t, limit(l, ping), 14, "grows while saturated")
+
+		// Once utilization falls below half, the limit stops growing.
+		release, err := l.acquire(ping)
+		assert.Nil(t, err, "acquire")
+		release(false)
+		assert.Equal(t, limit(l, ping), 14, "not saturated")
+
+		release, err = l.acquire(ping)
+		assert.Nil(t, err, "acquire")
+		*now = now.Add(2 * time.Second)
+		release(false)
+		assert.Equal(t, limit(l, ping), 12, "slow call shrinks limit")
+		release, err = l.acquire(ping)
+		assert.Nil(t, err, "acquire")
+		release(true)
+		assert.Equal(t, limit(l, ping), 11, "timeout shrinks limit")
+
+		for i := 0; i < 100; i++ {
+			release, _ := l.acquire(ping)
+			release(true)
+		}
+		assert.Equal(t, limit(l, ping), 1, "bounded by MinLimit")
+	})
+
+	t.Run("gradient", func(t *testing.T) {
+		l := &ConcurrencyLimiter{Algorithm: LimitGradient, Limit: 20, MaxLimit: 30}
+		now := newLimiter(l)
+		call := func(latency time.Duration, n int) {
+			var releases []func(bool)
+			for i := 0; i < n; i++ {
+				release, err := l.acquire(ping)
+				assert.Nil(t, err, "acquire")
+				releases = append(releases, release)
+			}
+			*now = now.Add(latency)
+			for _, release := range releases {
+				release(false)
+			}
+		}
+		for i := 0; i < 10; i++ {
+			call(10*time.Millisecond, 15)
+		}
+		steady := limit(l, ping)
+		assert.True(t, steady > 20, "grows while latency is stable")
+		assert.True(t, steady <= 30, "bounded by MaxLimit")
+		call(100*time.Millisecond, 15)
+		assert.True(t, limit(l, ping) < steady, "shrinks as latency rises")
+		shrunk := limit(l, ping)
+		call(10*time.Millisecond, 1)
+		assert.Equal(t, limit(l, ping), shrunk, "doesn't grow while underutilized")
+	})
+}
@@ -2,7 +2,6 @@ package rerpc
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -18,7 +17,10 @@ var (
 	// Marshal JSON with the options required by Twirp.
 	jsonpbMarshaler   = protojson.MarshalOptions{UseProtoNames: true}
 	jsonpbUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
-	sizeZeroPrefix    = make([]byte, 4)
+
+	// errTooLarge indicates that a message exceeded a configured size limit.
+	// Clients and handlers report it as CodeResourceExhausted.
+	errTooLarge = errors.New("message too large")
 )
 
 func marshalJSON(ctx context.Context, w io.Writer, msg proto.Message, hooks *Hooks) {
@@ -75,33 +77,37 @@ func unmarshalTwirpProto(r io.Reader, msg proto.Message) error {
 	return nil
 }
 
-func marshalLPM(ctx context.Context, w io.Writer, msg proto.Message, compression string, maxBytes int, hooks *Hooks) error {
+// marshalLPM writes a length-prefixed message. A nil Compressor leaves the
+// message uncompressed, as do marshaled messages smaller than minCompressBytes.
+func marshalLPM(ctx context.Context, w io.Writer, msg proto.Message, compressor Compressor, minCompressBytes, maxBytes int, hooks *Hooks) error {
 	raw, err := proto.Marshal(msg)
 	if err != nil {
 		err = fmt.Errorf("couldn't marshal protobuf message: %w", err)
 		hooks.onMarshalError(ctx, err)
 		return err
 	}
-	data := &bytes.Buffer{}
-	var dataW io.Writer = data
-	switch compression {
-	case CompressionIdentity:
-	case CompressionGzip:
-		dataW = gzip.NewWriter(data)
-	default:
-		err := fmt.Errorf("unsupported length-prefixed message compression %q", compression)
-		hooks.onInternalError(ctx, err)
-		return err
+	if len(raw) < minCompressBytes {
+		compressor = nil
 	}
-	_, err = dataW.Write(raw) // returns uncompressed size, which isn't useful
-	if err != nil {
-		err = fmt.Errorf("couldn't compress with %q: %w", compression, err)
-		hooks.onInternalError(ctx, err)
-		return err
-	}
-	if c, ok := dataW.(io.Closer); ok {
-		if err := c.Close(); err != nil {
-			err = fmt.Errorf("couldn't close writer with compression %q: %w", compression, err)
+	data := &bytes.Buffer{}
+	if compressor == nil {
+		data.Write(raw)
+	} else {
+		cw, err := compressor.Compress(data)
+		if err != nil {
+			err = fmt.Errorf("couldn't create compressor: %w", err)
+			hooks.onInternalError(ctx, err)
+			return err
+		}
+		_, err = cw.Write(raw) // returns uncompressed size, which isn't useful
+		if err != nil {
+			cw.Close()
+			err = fmt.Errorf("couldn't compress message: %w", err)
+			hooks.onInternalError(ctx, err)
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			err = fmt.Errorf("couldn't close compressor: %w", err)
 			hooks.onInternalError(ctx, err)
 			return err
 		}
@@ -109,12 +115,10 @@ func marshalLPM(ctx context.Context, w io.Writer, msg proto.Message, compression
 
 	size := data.Len()
 	if maxBytes > 0 && size > maxBytes {
-		return fmt.Errorf("message too large: got %d bytes, max is %d", size, maxBytes)
+		return fmt.Errorf("%w: got %d bytes, max is %d", errTooLarge, size, maxBytes)
 	}
 	prefixes := [5]byte{}
-	if compression == CompressionIdentity {
-		prefixes[0] = 0
-	} else {
+	if compressor != nil {
 		prefixes[0] = 1
 	}
 	binary.BigEndian.PutUint32(prefixes[1:5], uint32(size))
@@ -132,19 +136,25 @@ func marshalLPM(ctx context.Context, w io.Writer, msg proto.Message, compression
 	return nil
 }
 
-func unmarshalLPM(r io.Reader, msg proto.Message, compression string, maxBytes int) error {
+// unmarshalLPM reads a length-prefixed message. A nil Compressor indicates that
+// the message must be uncompressed. Even with a non-nil Compressor, senders may
+// leave individual messages uncompressed.
+//
+// The maxBytes limit applies to the message as sent, and maxDecompressedBytes
+// applies to the message after decompression. (For uncompressed messages,
+// both apply to the same data.) Decompression stops as soon as the message
+// exceeds its limit.
+func unmarshalLPM(r io.Reader, msg proto.Message, decompressor Compressor, maxBytes, maxDecompressedBytes int) error {
 	// Each length-prefixed message starts with 5 bytes of metadata: a one-byte
 	// unsigned integer indicating whether the payload is compressed, and a
-	// four-byte unsigned integer indicating the message length.
+	// four-byte unsigned integer indicating the message length. Streams may
+	// deliver this metadata across several reads, so we can't rely on a single
+	// call to Read.
 	prefixes := make([]byte, 5)
-	n, err := r.Read(prefixes)
-	if err != nil && errors.Is(err, io.EOF) && n == 5 && bytes.Equal(prefixes[1:5], sizeZeroPrefix) {
-		// Successfully read prefix, expect no additional data, and got an EOF, so
-		// there's nothing left to do - the zero value of the msg is correct.
-		return nil
-	} else if err != nil || n < 5 {
-		// Even an EOF is unacceptable here, since we always need a message for
-		// unary RPC.
+	if _, err := io.ReadFull(r, prefixes); err != nil {
+		// Unary RPCs always need a message, so even an EOF is unacceptable there.
+		// Streams use errors.Is(err, io.EOF) to detect a clean end of stream:
+		// io.ReadFull only returns io.EOF if it didn't read any bytes.
 		return fmt.Errorf("gRPC protocol error: missing length-prefixed message metadata: %w", err)
 	}
 
@@ -152,12 +162,9 @@ func unmarshalLPM(r io.Reader, msg proto.Message, compression string, maxBytes i
 	switch prefixes[0] {
 	case 0:
 		compressed = false
-		if compression != CompressionIdentity {
-			return fmt.Errorf("gRPC protocol error: protobuf is uncompressed but message compression is %q", compression)
-		}
 	case 1:
 		compressed = true
-		if compression == CompressionIdentity {
+		if decompressor == nil {
 			return errors.New("gRPC protocol error: protobuf is compressed but message should be uncompressed")
 		}
 	default:
@@ -169,13 +176,16 @@ func unmarshalLPM(r io.Reader, msg proto.Message, compression string, maxBytes i
 		return fmt.Errorf("message size %d overflows uint32", size)
 	}
 	if maxBytes > 0 && size > maxBytes {
-		return fmt.Errorf("message too large: got %d bytes, max is %d", size, maxBytes)
+		return fmt.Errorf("%w: got %d bytes, max is %d", errTooLarge, size, maxBytes)
+	}
+	if !compressed && maxDecompressedBytes > 0 && size > maxDecompressedBytes {
+		return fmt.Errorf("%w: got %d bytes, max is %d", errTooLarge, size, maxDecompressedBytes)
 	}
 
 	raw := make([]byte, size)
 	if size > 0 {
-		n, err = r.Read(raw)
-		if err != nil && err != io.EOF {
+		n, err := io.ReadFull(r, raw)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
 			return fmt.Errorf("error reading length-prefixed message data: %w", err)
 		}
 		if n < size {
@@ -183,15 +193,15 @@ func unmarshalLPM(r io.Reader, msg proto.Message, compression string, maxBytes i
 		}
 	}
 
-	if compressed && compression == CompressionGzip {
-		gr, err := gzip.NewReader(bytes.NewReader(raw))
+	if compressed {
+		dr, err := decompressor.Decompress(bytes.NewReader(raw))
 		if err != nil {
-			return fmt.Errorf("can't decompress gzipped data: %w", err)
+			return fmt.Errorf("can't decompress data: %w", err)
 		}
-		defer gr.Close()
-		decompressed, err := ioutil.ReadAll(gr)
+		defer dr.Close()
+		decompressed, err := ioutil.ReadAll(newLimitReader(dr, maxDecompressedBytes))
 		if err != nil {
-			return fmt.Errorf("can't decompress gzipped data: %w", err)
+			return fmt.Errorf("can't decompress data: %w", err)
 		}
 		raw = decompressed
 	}
@@ -202,3 +212,49 @@ func unmarshalLPM(r io.Reader, msg proto.Message, compression string, maxBytes i
 
 	return nil
 }
+
+// limitReader is like io.LimitedReader, but reading past the limit returns an
+// error wrapping errTooLarge rather than io.EOF. It's useful when the reader
+// is a decompressor, since it stops reading as soon as the limit is exceeded.
+type limitReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+// decompressedLimit returns the effective limit on decompressed messages.
+func decompressedLimit(maxBytes, maxDecompressedBytes int) int {
+	if maxDecompressedBytes > 0 {
+		return maxDecompressedBytes
+	}
+	return maxBytes
+}
+
+// newLimitReader limits the supplied reader to max bytes. Non-positive limits
+// leave the reader unlimited.
+func newLimitReader(r io.Reader, max int) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitReader{r: r, max: int64(max)}
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, l.tooLarge()
+	}
+	// Reading one byte past the limit is enough to detect overflow.
+	if remaining := l.max - l.read; int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n - int(l.read-l.max), l.tooLarge()
+	}
+	return n, err
+}
+
+func (l *limitReader) tooLarge() error {
+	return fmt.Errorf("%w: max is %d bytes", errTooLarge, l.max)
+}
@@ -0,0 +1,88 @@
+package rerpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rerpc/rerpc/internal/assert"
+	healthpb "github.com/rerpc/rerpc/internal/health/v1"
+)
+
+// lpm constructs a length-prefixed message.
+func lpm(t testing.TB, data []byte, compress bool) io.Reader {
+	t.Helper()
+	var flag byte
+	if compress {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		_, err := gw.Write(data)
+		assert.Nil(t, err, "compress")
+		assert.Nil(t, gw.Close(), "close gzip writer")
+		data = buf.Bytes()
+		flag = 1
+	}
+	prefix := make([]byte, 5)
+	prefix[0] = flag
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	return io.MultiReader(bytes.NewReader(prefix), bytes.NewReader(data))
+}
+
+func TestLimitReader(t *testing.T) {
+	const input = "hello"
+	bs, err := ioutil.ReadAll(newLimitReader(strings.NewReader(input), len(input)))
+	assert.Nil(t, err, "at limit")
+	assert.Equal(t, string(bs), input, "at limit")
+
+	bs, err = ioutil.ReadAll(newLimitReader(strings.NewReader(input), 0))
+	assert.Nil(t, err, "unlimited")
+	assert.Equal(t, string(bs), input, "unlimited")
+
+	r := newLimitReader(strings.NewReader(input), len(input)-1)
+	bs, err = ioutil.ReadAll(r)
+	assert.True(t, errors.Is(err, errTooLarge), "over limit")
+	assert.Equal(t, string(bs), input[:len(input)-1], "over limit")
+	_, err = r.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, errTooLarge), "error is sticky")
+}
+
+func TestUnmarshalLPMLimits(t *testing.T) {
+	gz := &gzipCompressor{}
+	msg, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: strings.Repeat("a", 64)})
+	assert.Nil(t, err, "marshal")
+	// A megabyte of zeros compresses to about a kilobyte.
+	bomb := make([]byte, 1<<20)
+
+	t.Run("within_limits", func(t *testing.T) {
+		var req healthpb.HealthCheckRequest
+		err := unmarshalLPM(lpm(t, msg, true), &req, gz, 1024, len(msg))
+		assert.Nil(t, err, "unmarshal")
+		assert.Equal(t, req.Service, strings.Repeat("a", 64), "service")
+	})
+	t.Run("compressed_too_large", func(t *testing.T) {
+		err := unmarshalLPM(lpm(t, bomb, true), &healthpb.HealthCheckRequest{}, gz, 16, 0)
+		assert.True(t, errors.Is(err, errTooLarge), "too large")
+	})
+	t.Run("decompressed_too_large", func(t *testing.T) {
+		err := unmarshalLPM(lpm(t, bomb, true), &healthpb.HealthCheckRequest{}, gz, 1<<20, 1024)
+		assert.True(t, errors.Is(err, errTooLarge), "too large")
+		assert.Match(t, err.Error(), "max is 1024 bytes", "error message")
+	})
+	t.Run("uncompressed_too_large", func(t *testing.T) {
+		err := unmarshalLPM(lpm(t, msg, false), &healthpb.HealthCheckRequest{}, gz, 1024, len(msg)-1)
+		assert.True(t, errors.Is(err, errTooLarge), "too large")
+	})
+}
+
+func TestDecompressedLimit(t *testing.T) {
+	assert.Equal(t, decompressedLimit(0, 0), 0, "unlimited")
+	assert.Equal(t, decompressedLimit(10, 0), 10, "defaults to compressed limit")
+	assert.Equal(t, decompressedLimit(10, 100), 100, "explicit limit")
+}
@@ -10,6 +10,7 @@ type ctxk int
 const (
 	callMetaKey ctxk = iota
 	handlerMetaKey
+	requestSentKey
 )
 
 // Specification is a description of a client call or a handler invocation.
@@ -17,6 +18,7 @@ const (
 // Note that the Method, Service, and Package are fully-qualified protobuf
 // names, not Go import paths or identifiers.
 type Specification struct {
+	Type    StreamType
 	Method  string // full protobuf name, e.g. "acme.foo.v1.FooService.Bar"
 	Service string // full protobuf name, e.g. "acme.foo.v1.FooService"
 	Package string // full protobuf name, e.g. "acme.foo.v1"
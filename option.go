@@ -14,7 +14,9 @@ type readMaxBytes struct {
 // ReadMaxBytes limits the performance impact of pathologically large messages
 // sent by the other party. For handlers, ReadMaxBytes sets the maximum
 // allowable request size. For clients, ReadMaxBytes sets the maximum allowable
-// response size. Limits are applied before decompression.
+// response size. Limits are applied before decompression and, unless
+// ReadMaxDecompressedBytes sets a separate limit, again after decompression.
+// Messages over the limit fail with CodeResourceExhausted.
 //
 // Setting ReadMaxBytes to zero allows any request size. Both clients and
 // handlers default to allowing any request size.
@@ -30,6 +32,56 @@ func (o *readMaxBytes) applyToHandler(cfg *handlerCfg) {
 	cfg.MaxRequestBytes = o.Max
 }
 
+type readMaxDecompressedBytes struct {
+	Max int
+}
+
+// ReadMaxDecompressedBytes limits the size of messages after decompression,
+// so small but highly-compressed messages can't exhaust memory. The limit is
+// enforced while decompressing, so reRPC stops reading as soon as a message
+// exceeds it. Messages over the limit fail with CodeResourceExhausted.
+//
+// By default, the limit set by ReadMaxBytes applies both before and after
+// decompression. Setting ReadMaxDecompressedBytes to a positive value
+// overrides the limit after decompression, which is useful when messages
+// compress well.
+func ReadMaxDecompressedBytes(n int) Option {
+	return &readMaxDecompressedBytes{n}
+}
+
+func (o *readMaxDecompressedBytes) applyToCall(cfg *callCfg) {
+	cfg.MaxDecompressedBytes = o.Max
+}
+
+func (o *readMaxDecompressedBytes) applyToHandler(cfg *handlerCfg) {
+	cfg.MaxDecompressedBytes = o.Max
+}
+
+type writeMaxBytes struct {
+	Max int
+}
+
+// WriteMaxBytes limits the size of messages sent to the other party. For
+// clients, WriteMaxBytes sets the maximum allowable request size: larger
+// requests fail with CodeResourceExhausted before reRPC sends anything to the
+// server. For handlers, WriteMaxBytes sets the maximum allowable response
+// size: rather than sending a larger response, handlers send an error with
+// CodeResourceExhausted. Limits are applied after compression.
+//
+// Setting WriteMaxBytes to zero allows any message size. Both clients and
+// handlers default to allowing any message size.
+func WriteMaxBytes(n int) Option {
+	return &writeMaxBytes{n}
+}
+
+func (o *writeMaxBytes) applyToCall(cfg *callCfg) {
+	cfg.MaxRequestBytes = o.Max
+}
+
+func (o *writeMaxBytes) applyToHandler(cfg *handlerCfg) {
+	cfg.MaxResponseBytes = o.Max
+}
+
 type gzipOption struct {
 	Enable bool
 }
@@ -52,9 +104,41 @@ func (o *gzipOption) applyToCall(cfg *callCfg) {
 	// NB, the default is required by
 	// https://github.com/grpc/grpc/blob/master/doc/compression.md - see test
 	// case 6.
-	cfg.EnableGzipRequest = o.Enable
+	if o.Enable {
+		cfg.RequestCompression = CompressionGzip
+	} else {
+		cfg.RequestCompression = CompressionIdentity
+	}
 }
 
 func (o *gzipOption) applyToHandler(cfg *handlerCfg) {
 	cfg.DisableGzipResponse = !o.Enable
 }
+
+type compressMinBytes struct {
+	Min int
+}
+
+// CompressMinBytes sets a minimum size for compression. Messages smaller than
+// the minimum are sent uncompressed, since compressing them often makes them
+// larger and wastes CPU. For handlers, CompressMinBytes applies to responses.
+// For clients, it applies to requests. Sizes are measured before compression.
+//
+// gRPC messages below the minimum are sent with the compressed flag unset,
+// but the Grpc-Encoding header still advertises the negotiated compression
+// method. Twirp messages below the minimum are sent without a
+// Content-Encoding header.
+//
+// Setting CompressMinBytes to zero compresses messages of any size. Both
+// clients and handlers default to compressing messages of any size.
+func CompressMinBytes(n int) Option {
+	return &compressMinBytes{n}
+}
+
+func (o *compressMinBytes) applyToCall(cfg *callCfg) {
+	cfg.CompressMinBytes = o.Min
+}
+
+func (o *compressMinBytes) applyToHandler(cfg *handlerCfg) {
+	cfg.CompressMinBytes = o.Min
+}
@@ -0,0 +1,365 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	healthpb "github.com/rerpc/rerpc/internal/health/v1"
+)
+
+// Defaults for the zero values of HealthProbe's fields.
+const (
+	defaultProbeInterval = 10 * time.Second
+	defaultProbeTimeout  = time.Second
+)
+
+// A HealthProbe checks one of a server's dependencies, like a database or a
+// downstream service. The Check function should return an error if the
+// dependency is unhealthy; RemoteHealthCheck builds Check functions for
+// downstream servers that implement gRPC's health-checking API.
+type HealthProbe struct {
+	// Name identifies the probe in readiness reports. It's required, and each
+	// of a HealthAggregator's probes must have a unique name.
+	Name string
+	// Check runs the probe. It's required.
+	Check func(context.Context) error
+	// Services lists the fully-qualified protobuf services that depend on the
+	// probe. If empty, the whole process depends on the probe, so it affects
+	// the process's health and the health of every service.
+	Services []string
+	// Interval is how often to run the probe. If zero, HealthAggregators use
+	// 10 seconds.
+	Interval time.Duration
+	// Timeout bounds each run of the probe. If zero, HealthAggregators use one
+	// second.
+	Timeout time.Duration
+}
+
+func (p *HealthProbe) interval() time.Duration {
+	if p.Interval <= 0 {
+		return defaultProbeInterval
+	}
+	return p.Interval
+}
+
+func (p *HealthProbe) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return p.Timeout
+}
+
+// covers reports whether a service depends on the probe. The empty service
+// name refers to the process as a whole.
+func (p *HealthProbe) covers(service string) bool {
+	if len(p.Services) == 0 {
+		return true
+	}
+	for _, s := range p.Services {
+		if s == service && s != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// A HealthAggregator computes health from HealthProbes, so statuses follow
+// the health of the server's dependencies instead of being maintained by
+// hand. Each probe runs in the background on its own interval. A service is
+// HealthNotServing if any probe it depends on failed its most recent run,
+// HealthUnknown if any of those probes hasn't finished its first run, and
+// HealthServing otherwise. The process as a whole (the empty service name)
+// depends only on probes that don't list any Services.
+//
+// Services are known if they're registered with the Registrar or listed by
+// any probe. HealthAggregators implement HealthNotifier, so changes reach
+// clients of the streaming Watch method as soon as a probe's result changes:
+//
+//   health := &rerpc.HealthAggregator{Registrar: reg, Probes: probes}
+//   defer health.Close()
+//   mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health, reg))
+//   mux.Handle("/readyz", health)
+//
+// HealthAggregators also implement http.Handler, serving a JSON readiness
+// report for plain HTTP probes, like Kubernetes readiness checks. To drain the
+// process before it exits, call Shutdown (or use a Drainer), which reports
+// everything as HealthNotServing regardless of the probes' results.
+//
+// HealthAggregators start their probes on first use, and the first check
+// waits (within its context's deadline) for each probe's first result. Call
+// Close to stop the probes. A HealthAggregator's fields must not be modified
+// after its first use, and HealthAggregators must not be copied after first
+// use. They're safe to use concurrently.
+type HealthAggregator struct {
+	// Registrar, if non-nil, makes every registered service known, even if no
+	// probe lists it.
+	Registrar *Registrar
+	// Probes are the dependencies to check.
+	Probes []HealthProbe
+
+	start   sync.Once
+	ready   chan struct{} // closed once every probe has finished a run
+	cancel  context.CancelFunc
+	changed healthBroadcast
+
+	mu       sync.RWMutex
+	results  []HealthStatus // by probe index
+	pending  int            // probes that haven't finished a run
+	shutdown bool
+}
+
+var (
+	_ HealthNotifier = (*HealthAggregator)(nil)
+	_ http.Handler   = (*HealthAggregator)(nil)
+)
+
+// Check returns the health of a fully-qualified protobuf service, or of the
+// whole process if the service name is empty. It returns a CodeNotFound error
+// for unknown services. Check satisfies the requirements of NewHealthHandler.
+func (a *HealthAggregator) Check(ctx context.Context, service string) (HealthStatus, error) {
+	a.start.Do(a.run)
+	select {
+	case <-a.ready:
+	case <-ctx.Done():
+		// Report the probes that have finished, treating the rest as unknown.
+	}
+	if !a.isKnown(service) {
+		return HealthUnknown, errorf(CodeNotFound, "unknown service %s", service)
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.shutdown {
+		return HealthNotServing, nil
+	}
+	status := HealthServing
+	for i := range a.Probes {
+		if a.Probes[i].covers(service) {
+			status = worseHealth(status, a.results[i])
+		}
+	}
+	return status, nil
+}
+
+// Changed implements HealthNotifier.
+func (a *HealthAggregator) Changed() <-chan struct{} {
+	a.start.Do(a.run)
+	return a.changed.wait()
+}
+
+// Shutdown reports every known service, and the process as a whole, as
+// HealthNotServing, even if all their probes succeed. Probes keep running, so
+// Resume restores the probes' view of health immediately.
+func (a *HealthAggregator) Shutdown() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.shutdown {
+		a.shutdown = true
+		a.changed.notify()
+	}
+}
+
+// Resume undoes Shutdown.
+func (a *HealthAggregator) Resume() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.shutdown {
+		a.shutdown = false
+		a.changed.notify()
+	}
+}
+
+// Close stops running probes. Checks after Close use each probe's most recent
+// result.
+func (a *HealthAggregator) Close() error {
+	a.start.Do(func() {
+		// Never run the probes, and don't make checks wait for them.
+		a.results = make([]HealthStatus, len(a.Probes))
+		a.ready = make(chan struct{})
+		close(a.ready)
+	})
+	if a.cancel != nil {
+		a.cancel()
+		// Probes that never finished won't report now, so stop waiting for them.
+		a.mu.Lock()
+		if a.pending > 0 {
+			a.pending = 0
+			close(a.ready)
+		}
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// healthReport is the JSON readiness report.
+type healthReport struct {
+	Service string            `json:"service"`
+	Status  string            `json:"status"`
+	Probes  map[string]string `json:"probes,omitempty"`
+}
+
+// ServeHTTP writes a JSON readiness report for the service named in the
+// "service" query parameter, or for the whole process if the parameter is
+// absent. The report includes the overall status and the result of each
+// relevant probe, but not the probes' errors. The HTTP status is 200 if the
+// service is HealthServing, 404 if the service is unknown, and 503 otherwise.
+func (a *HealthAggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	service := r.URL.Query().Get("service")
+	report := healthReport{Service: service}
+	code := http.StatusServiceUnavailable
+	status, err := a.Check(r.Context(), service)
+	if err != nil {
+		report.Status = HealthServiceUnknown.String()
+		code = http.StatusNotFound
+	} else {
+		report.Status = status.String()
+		if status == HealthServing {
+			code = http.StatusOK
+		}
+		report.Probes = make(map[string]string)
+		a.mu.RLock()
+		for i := range a.Probes {
+			if a.Probes[i].covers(service) {
+				report.Probes[a.Probes[i].Name] = a.results[i].String()
+			}
+		}
+		a.mu.RUnlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// run starts the probes in the background.
+func (a *HealthAggregator) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.results = make([]HealthStatus, len(a.Probes))
+	a.pending = len(a.Probes)
+	a.ready = make(chan struct{})
+	if a.pending == 0 {
+		close(a.ready)
+	}
+	for i := range a.Probes {
+		go a.runProbe(ctx, i)
+	}
+}
+
+// runProbe runs a probe immediately and then every interval until the
+// context is canceled.
+func (a *HealthAggregator) runProbe(ctx context.Context, i int) {
+	probe := &a.Probes[i]
+	ticker := time.NewTicker(probe.interval())
+	defer ticker.Stop()
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, probe.timeout())
+		err := probe.Check(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		a.record(i, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record saves the result of a probe run, notifying watchers if it changed.
+func (a *HealthAggregator) record(i int, err error) {
+	status := HealthServing
+	if err != nil {
+		status = HealthNotServing
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.results[i] == HealthUnknown && a.pending > 0 {
+		a.pending--
+		if a.pending == 0 {
+			close(a.ready)
+		}
+	}
+	if a.results[i] != status {
+		a.results[i] = status
+		a.changed.notify()
+	}
+}
+
+func (a *HealthAggregator) isKnown(service string) bool {
+	if service == "" {
+		return true
+	}
+	if a.Registrar != nil && a.Registrar.IsRegistered(service) {
+		return true
+	}
+	for i := range a.Probes {
+		for _, s := range a.Probes[i].Services {
+			if s == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// worseHealth returns the less healthy of two statuses.
+func worseHealth(a, b HealthStatus) HealthStatus {
+	rank := func(s HealthStatus) int {
+		switch s {
+		case HealthServing:
+			return 0
+		case HealthUnknown:
+			return 1
+		default:
+			return 2
+		}
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// RemoteHealthCheck builds a HealthProbe Check function for a downstream
+// server that implements gRPC's health-checking API, like servers using
+// NewHealthHandler. The probe calls the Check method at the server's base URL
+// (for example, "https://api.acme.com") and fails unless the named service
+// (or, if the name is empty, the whole server) is HealthServing.
+func RemoteHealthCheck(doer Doer, baseURL, service string, opts ...CallOption) func(context.Context) error {
+	client := NewClient(
+		doer,
+		baseURL+"/"+healthServiceFQN+"/Check",
+		healthServiceFQN+".Check",
+		healthServiceFQN,
+		healthPackageFQN,
+		func() proto.Message { return &healthpb.HealthCheckResponse{} },
+		opts...,
+	)
+	return func(ctx context.Context) error {
+		res, err := client.Call(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		typed, ok := res.(*healthpb.HealthCheckResponse)
+		if !ok {
+			return errorf(CodeInternal, "expected health response, got %T", res)
+		}
+		if status := HealthStatus(typed.Status); status != HealthServing {
+			return fmt.Errorf("remote health is %v", status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,226 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+// switchProbe is a probe function that fails while its flag is set.
+type switchProbe struct {
+	failing int32
+}
+
+func (p *switchProbe) Check(context.Context) error {
+	if atomic.LoadInt32(&p.failing) != 0 {
+		return errors.New("oh no")
+	}
+	return nil
+}
+
+func (p *switchProbe) Set(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&p.failing, v)
+}
+
+func TestHealthAggregator(t *testing.T) {
+	const (
+		fooFQN = "acme.foo.v1.FooService"
+		barFQN = "acme.bar.v1.BarService"
+		bazFQN = "acme.baz.v1.BazService"
+	)
+	reg := NewRegistrar()
+	reg.register(barFQN)
+	db, downstream := &switchProbe{}, &switchProbe{}
+	agg := &HealthAggregator{
+		Registrar: reg,
+		Probes: []HealthProbe{
+			{Name: "db", Check: db.Check, Interval: time.Millisecond},
+			{Name: "downstream", Check: downstream.Check, Services: []string{fooFQN}, Interval: time.Millisecond},
+		},
+	}
+	defer agg.Close()
+	check := func(t testing.TB, service string, expect HealthStatus) {
+		t.Helper()
+		status, err := agg.Check(context.Background(), service)
+		assert.Nil(t, err, "check %q", assert.Fmt(service))
+		assert.Equal(t, status, expect, "status of %q", assert.Fmt(service))
+	}
+	// waitFor waits until a change brings the service to the expected status.
+	waitFor := func(t testing.TB, service string, expect HealthStatus) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			changed := agg.Changed()
+			if status, _ := agg.Check(context.Background(), service); status == expect {
+				return
+			}
+			select {
+			case <-changed:
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q to be %v", service, expect)
+			}
+		}
+	}
+
+	check(t, "", HealthServing)
+	check(t, fooFQN, HealthServing)
+	check(t, barFQN, HealthServing)
+	_, err := agg.Check(context.Background(), bazFQN)
+	assert.Equal(t, CodeOf(err), CodeNotFound, "unknown service")
+
+	downstream.Set(true)
+	waitFor(t, fooFQN, HealthNotServing)
+	check(t, "", HealthServing)
+	check(t, barFQN, HealthServing)
+
+	db.Set(true)
+	waitFor(t, "", HealthNotServing)
+	check(t, barFQN, HealthNotServing)
+
+	db.Set(false)
+	downstream.Set(false)
+	waitFor(t, "", HealthServing)
+	waitFor(t, fooFQN, HealthServing)
+}
+
+func TestHealthAggregatorFirstCheck(t *testing.T) {
+	release := make(chan struct{})
+	agg := &HealthAggregator{
+		Probes: []HealthProbe{{
+			Name: "slow",
+			Check: func(ctx context.Context) error {
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+			Timeout: time.Minute,
+		}},
+	}
+	defer agg.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	status, err := agg.Check(ctx, "")
+	assert.Nil(t, err, "check before first result")
+	assert.Equal(t, status, HealthUnknown, "status before first result")
+
+	close(release)
+	status, err = agg.Check(context.Background(), "")
+	assert.Nil(t, err, "check after first result")
+	assert.Equal(t, status, HealthServing, "status after first result")
+}
+
+func TestHealthAggregatorClose(t *testing.T) {
+	agg := &HealthAggregator{
+		Probes: []HealthProbe{{
+			Name: "stuck",
+			Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			Timeout: time.Minute,
+		}},
+	}
+	agg.Changed() // start probing
+	assert.Nil(t, agg.Close(), "close")
+	status, err := agg.Check(context.Background(), "")
+	assert.Nil(t, err, "check after close")
+	assert.Equal(t, status, HealthUnknown, "status after close")
+
+	unused := &HealthAggregator{Probes: agg.Probes}
+	assert.Nil(t, unused.Close(), "close before use")
+	status, err = unused.Check(context.Background(), "")
+	assert.Nil(t, err, "check after close")
+	assert.Equal(t, status, HealthUnknown, "status after close")
+}
+
+func TestHealthAggregatorReadiness(t *testing.T) {
+	const fooFQN = "acme.foo.v1.FooService"
+	downstream := &switchProbe{}
+	downstream.Set(true)
+	agg := &HealthAggregator{
+		Probes: []HealthProbe{
+			{Name: "db", Check: (&switchProbe{}).Check},
+			{Name: "downstream", Check: downstream.Check, Services: []string{fooFQN}},
+		},
+	}
+	defer agg.Close()
+	get := func(t testing.TB, target string) (int, healthReport) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		agg.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json", "content type")
+		var report healthReport
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report), "unmarshal report")
+		return w.Code, report
+	}
+
+	code, report := get(t, "/readyz")
+	assert.Equal(t, code, http.StatusOK, "process HTTP status")
+	assert.Equal(t, report, healthReport{
+		Status: "SERVING",
+		Probes: map[string]string{"db": "SERVING"},
+	}, "process report")
+
+	code, report = get(t, "/readyz?service="+fooFQN)
+	assert.Equal(t, code, http.StatusServiceUnavailable, "service HTTP status")
+	assert.Equal(t, report, healthReport{
+		Service: fooFQN,
+		Status:  "NOT_SERVING",
+		Probes:  map[string]string{"db": "SERVING", "downstream": "NOT_SERVING"},
+	}, "service report")
+
+	code, report = get(t, "/readyz?service=acme.bar.v1.BarService")
+	assert.Equal(t, code, http.StatusNotFound, "unknown service HTTP status")
+	assert.Equal(t, report.Status, "SERVICE_UNKNOWN", "unknown service status")
+
+	w := httptest.NewRecorder()
+	agg.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	assert.Equal(t, w.Code, http.StatusMethodNotAllowed, "POST HTTP status")
+}
+
+func TestHealthAggregatorShutdown(t *testing.T) {
+	const fooFQN = "acme.foo.v1.FooService"
+	agg := &HealthAggregator{
+		Probes: []HealthProbe{{Name: "downstream", Check: (&switchProbe{}).Check, Services: []string{fooFQN}}},
+	}
+	defer agg.Close()
+	check := func(t testing.TB, service string, expect HealthStatus) {
+		t.Helper()
+		status, err := agg.Check(context.Background(), service)
+		assert.Nil(t, err, "check %q", assert.Fmt(service))
+		assert.Equal(t, status, expect, "status of %q", assert.Fmt(service))
+	}
+
+	check(t, "", HealthServing)
+	check(t, fooFQN, HealthServing)
+	changed := agg.Changed()
+	agg.Shutdown()
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Shutdown didn't notify watchers")
+	}
+	check(t, "", HealthNotServing)
+	check(t, fooFQN, HealthNotServing)
+	_, err := agg.Check(context.Background(), "acme.bar.v1.BarService")
+	assert.Equal(t, CodeOf(err), CodeNotFound, "unknown service after shutdown")
+
+	agg.Resume()
+	check(t, "", HealthServing)
+	check(t, fooFQN, HealthServing)
+}
@@ -0,0 +1,69 @@
+package rerpc
+
+import (
+	"context"
+	"time"
+)
+
+// A Propagation forwards request-scoped context from a handler to the calls
+// it makes downstream. When a client call's context descends from a handler's
+// context (that is, when HandlerMeta succeeds), the client copies the
+// allow-listed headers from the handler's request to its own and shortens
+// the inherited deadline by DeadlineMargin. Outside handlers, Propagations
+// have no effect.
+//
+// The handler's deadline, set from the inbound Grpc-Timeout header, already
+// flows to downstream calls through the context. DeadlineMargin reserves some
+// of that budget, so the handler has time to act on a downstream failure and
+// respond before its own caller gives up.
+//
+// Propagations are valid CallOptions. They apply to both unary and streaming
+// calls, and they take effect before Interceptors run, so Interceptors can
+// still modify the propagated headers.
+type Propagation struct {
+	// Headers lists the inbound request headers to copy, like "Traceparent"
+	// or "Tenant-Id". Reserved headers (as defined by IsReservedHeader) can't
+	// be propagated, and values already set on the outbound request are
+	// replaced.
+	Headers []string
+	// DeadlineMargin is subtracted from the inbound deadline. If the margin
+	// leaves no time for the call, it fails with CodeDeadlineExceeded without
+	// contacting the server.
+	DeadlineMargin time.Duration
+}
+
+func (p *Propagation) applyToCall(cfg *callCfg) {
+	cfg.Propagation = p
+}
+
+// apply propagates headers and the deadline from the inbound request, if any,
+// to the outbound call's context. Callers must call the returned CancelFunc
+// once the call completes.
+func (p *Propagation) apply(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p == nil {
+		return ctx, func() {}
+	}
+	inbound, ok := HandlerMeta(ctx)
+	if !ok {
+		return ctx, func() {}
+	}
+	if outbound, ok := CallMeta(ctx); ok {
+		req := outbound.Request()
+		for _, key := range p.Headers {
+			values := inbound.Request().Values(key)
+			if len(values) == 0 {
+				continue
+			}
+			if err := req.Del(key); err != nil {
+				continue // reserved
+			}
+			for _, v := range values {
+				req.Add(key, v)
+			}
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && p.DeadlineMargin > 0 {
+		return context.WithDeadline(ctx, deadline.Add(-p.DeadlineMargin))
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,56 @@
+package rerpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestPropagation(t *testing.T) {
+	p := &Propagation{
+		Headers:        []string{"trace-id", "Tenant-Id", "Grpc-Timeout", "Missing"},
+		DeadlineMargin: time.Second,
+	}
+	inbound := http.Header{
+		"Trace-Id":     {"abc", "def"},
+		"Tenant-Id":    {"acme"},
+		"Grpc-Timeout": {"1S"},
+		"Secret":       {"hunter2"},
+	}
+	deadline := time.Now().Add(time.Minute)
+	handlerCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	handlerCtx = NewHandlerContext(handlerCtx, Specification{}, inbound, make(http.Header))
+
+	t.Run("handler", func(t *testing.T) {
+		outbound := http.Header{"Tenant-Id": {"other"}}
+		ctx := NewCallContext(handlerCtx, Specification{}, outbound, make(http.Header))
+		ctx, cancel := p.apply(ctx)
+		defer cancel()
+		assert.Equal(t, outbound, http.Header{
+			"Trace-Id":  {"abc", "def"},
+			"Tenant-Id": {"acme"},
+		}, "propagated headers")
+		got, ok := ctx.Deadline()
+		assert.True(t, ok, "has deadline")
+		assert.Equal(t, got, deadline.Add(-time.Second), "deadline")
+	})
+	t.Run("no_handler", func(t *testing.T) {
+		outbound := make(http.Header)
+		ctx := NewCallContext(context.Background(), Specification{}, outbound, make(http.Header))
+		ctx, cancel := p.apply(ctx)
+		defer cancel()
+		assert.Zero(t, len(outbound), "no headers")
+		_, ok := ctx.Deadline()
+		assert.False(t, ok, "no deadline")
+	})
+	t.Run("nil", func(t *testing.T) {
+		var p *Propagation
+		ctx, cancel := p.apply(handlerCtx)
+		defer cancel()
+		assert.True(t, ctx == handlerCtx, "unchanged context")
+	})
+}
@@ -0,0 +1,210 @@
+package rerpc
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	errdetailspb "github.com/rerpc/rerpc/internal/errdetails/v1"
+)
+
+// retryInfoTypeURL identifies google.rpc.RetryInfo error details, which
+// errdetailspb.RetryInfo is binary-compatible with.
+const retryInfoTypeURL = "type.googleapis.com/google.rpc.RetryInfo"
+
+// sweepInterval is how often MemoryRateLimitStores discard idle buckets.
+const sweepInterval = time.Minute
+
+// A RateLimiter is an Interceptor that limits the rate of calls using token
+// buckets, typically one per caller. Each call takes a token from its bucket,
+// and each bucket refills at a steady Rate up to a maximum of Burst tokens.
+// Calls that find their bucket empty fail with CodeResourceExhausted, and
+// their errors include a google.rpc.RetryInfo detail saying when the next
+// token will be available.
+//
+// The Key function assigns calls to buckets. On handlers, it typically uses
+// HandlerMeta to read an API key, tenant ID, or forwarded client address from
+// the request headers.
+//
+// RateLimiters limit streaming calls when they open. By default, they keep
+// their buckets in memory, so each process enforces its limits independently;
+// to share limits between processes, use a RateLimitStore backed by a shared
+// database. A RateLimiter's fields must not be modified after its first use.
+type RateLimiter struct {
+	// Rate is the number of calls allowed per second, on average. It must be
+	// positive.
+	Rate float64
+	// Burst is the maximum number of calls allowed at once. If zero,
+	// RateLimiters use Rate, rounded up.
+	Burst int
+	// Key returns the bucket for a call. If nil, all calls share a single
+	// bucket.
+	Key func(context.Context) string
+	// Store holds the buckets. If nil, RateLimiters keep their buckets in
+	// memory.
+	Store RateLimitStore
+
+	memory MemoryRateLimitStore
+}
+
+var _ StreamInterceptor = (*RateLimiter)(nil)
+
+// Wrap implements Interceptor.
+func (l *RateLimiter) Wrap(next Func) Func {
+	return Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		if err := l.take(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	})
+}
+
+// WrapCallStream implements StreamInterceptor.
+func (l *RateLimiter) WrapCallStream(next CallStreamFunc) CallStreamFunc {
+	return CallStreamFunc(func(ctx context.Context) (Stream, error) {
+		if err := l.take(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx)
+	})
+}
+
+// WrapHandlerStream implements StreamInterceptor.
+func (l *RateLimiter) WrapHandlerStream(next HandlerStreamFunc) HandlerStreamFunc {
+	return HandlerStreamFunc(func(ctx context.Context, stream Stream) error {
+		if err := l.take(ctx); err != nil {
+			return err
+		}
+		return next(ctx, stream)
+	})
+}
+
+// take removes a token from the call's bucket, returning an error if the
+// bucket is empty.
+func (l *RateLimiter) take(ctx context.Context) *Error {
+	var key string
+	if l.Key != nil {
+		key = l.Key(ctx)
+	}
+	var store RateLimitStore = &l.memory
+	if l.Store != nil {
+		store = l.Store
+	}
+	ok, wait, err := store.Take(ctx, key, l.Rate, l.burst())
+	if err != nil {
+		return errorf(CodeUnavailable, "can't check rate limit: %w", err)
+	}
+	if ok {
+		return nil
+	}
+	// Don't include the key in the message, since it may be a credential.
+	rerr := errorf(CodeResourceExhausted, "rate limit exceeded: retry in %v", wait.Round(time.Millisecond))
+	if detail, err := newRetryInfo(wait); err == nil {
+		rerr.AddDetail(detail)
+	}
+	return rerr
+}
+
+func (l *RateLimiter) burst() int {
+	if l.Burst <= 0 {
+		return int(math.Max(1, math.Ceil(l.Rate)))
+	}
+	return l.Burst
+}
+
+// newRetryInfo constructs a google.rpc.RetryInfo error detail.
+func newRetryInfo(delay time.Duration) (*anypb.Any, error) {
+	info, err := proto.Marshal(&errdetailspb.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return nil, err
+	}
+	return &anypb.Any{TypeUrl: retryInfoTypeURL, Value: info}, nil
+}
+
+// A RateLimitStore holds the token buckets for RateLimiters. Implementations
+// must be safe to use concurrently.
+//
+// Take tries to remove a token from the key's bucket, which refills at rate
+// tokens per second up to a maximum of burst tokens. New buckets start full.
+// If the bucket is empty, Take returns false and how long the caller should
+// wait for a token. If Take returns an error, the call fails with
+// CodeUnavailable; stores that would rather allow calls during outages should
+// return true instead.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// A MemoryRateLimitStore is a RateLimitStore that keeps its buckets in
+// memory. It periodically discards buckets that have refilled, so it doesn't
+// grow without bound as callers come and go. The zero value is ready to use.
+type MemoryRateLimitStore struct {
+	now func() time.Time // for tests
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+var _ RateLimitStore = (*MemoryRateLimitStore)(nil)
+
+// tokenBucket is the state of a single bucket.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will be full again
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	now := s.clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets == nil {
+		s.buckets = make(map[string]*tokenBucket)
+		s.lastSweep = now
+	}
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = b
+	}
+	if rate > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+rate*now.Sub(b.updated).Seconds())
+	}
+	b.updated = now
+	if b.tokens < 1 {
+		if rate <= 0 {
+			// The bucket never refills.
+			return false, time.Duration(math.MaxInt64), nil
+		}
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, wait, nil
+	}
+	b.tokens--
+	if rate > 0 {
+		b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	} else {
+		b.full = now.Add(time.Duration(math.MaxInt64))
+	}
+	return true, 0, nil
+}
+
+func (s *MemoryRateLimitStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,112 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rerpc/rerpc/internal/assert"
+	errdetailspb "github.com/rerpc/rerpc/internal/errdetails/v1"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, float64, int) (bool, time.Duration, error) {
+	return false, 0, errors.New("oh no")
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	store := &MemoryRateLimitStore{now: func() time.Time { return now }}
+	take := func(key string) (bool, time.Duration) {
+		ok, wait, err := store.Take(ctx, key, 2 /* rate */, 3 /* burst */)
+		assert.Nil(t, err, "take")
+		return ok, wait
+	}
+
+	for i := 0; i < 3; i++ {
+		ok, _ := take("foo")
+		assert.True(t, ok, "burst")
+	}
+	ok, wait := take("foo")
+	assert.False(t, ok, "empty")
+	assert.Equal(t, wait, 500*time.Millisecond, "wait")
+	ok, _ = take("bar")
+	assert.True(t, ok, "separate buckets")
+
+	now = now.Add(250 * time.Millisecond)
+	ok, wait = take("foo")
+	assert.False(t, ok, "partially refilled")
+	assert.Equal(t, wait, 250*time.Millisecond, "wait")
+	now = now.Add(250 * time.Millisecond)
+	ok, _ = take("foo")
+	assert.True(t, ok, "refilled")
+
+	// Refilled buckets are discarded.
+	now = now.Add(sweepInterval)
+	take("baz")
+	assert.Equal(t, len(store.buckets), 1, "swept buckets")
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	next := Func(func(context.Context, proto.Message) (proto.Message, error) {
+		return nil, nil
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		limiter := &RateLimiter{Rate: 0.5}
+		limiter.memory.now = func() time.Time { return time.Unix(0, 0) }
+		call := limiter.Wrap(next)
+		_, err := call(ctx, nil)
+		assert.Nil(t, err, "first call")
+		_, err = call(ctx, nil)
+		assert.Equal(t, CodeOf(err), CodeResourceExhausted, "error code")
+		assert.Match(t, err.Error(), "retry in 2s", "error message")
+
+		rerr, ok := AsError(err)
+		assert.True(t, ok, "rerpc error")
+		details := rerr.Details()
+		assert.Equal(t, len(details), 1, "details")
+		assert.Equal(t, details[0].TypeUrl, "type.googleapis.com/google.rpc.RetryInfo", "type URL")
+		var info errdetailspb.RetryInfo
+		assert.Nil(t, proto.Unmarshal(details[0].Value, &info), "unmarshal detail")
+		assert.Equal(t, info.RetryDelay.AsDuration(), 2*time.Second, "retry delay")
+	})
+
+	t.Run("key", func(t *testing.T) {
+		limiter := &RateLimiter{
+			Rate:  1,
+			Burst: 2,
+			Key: func(ctx context.Context) string {
+				md, _ := HandlerMeta(ctx)
+				return md.Request().Get("Api-Key")
+			},
+		}
+		limiter.memory.now = func() time.Time { return time.Unix(0, 0) }
+		stream := limiter.WrapHandlerStream(func(context.Context, Stream) error {
+			return nil
+		})
+		keyed := func(key string) context.Context {
+			return NewHandlerContext(ctx, Specification{}, map[string][]string{"Api-Key": {key}}, nil)
+		}
+		for i := 0; i < 2; i++ {
+			assert.Nil(t, stream(keyed("foo"), nil), "within burst")
+		}
+		assert.Equal(t, CodeOf(stream(keyed("foo"), nil)), CodeResourceExhausted, "over burst")
+		assert.Nil(t, stream(keyed("bar"), nil), "other key")
+	})
+
+	t.Run("store_error", func(t *testing.T) {
+		limiter := &RateLimiter{Rate: 1, Store: failingRateLimitStore{}}
+		_, err := limiter.WrapCallStream(func(context.Context) (Stream, error) {
+			return nil, nil
+		})(ctx)
+		assert.Equal(t, CodeOf(err), CodeUnavailable, "error code")
+		assert.Match(t, err.Error(), "oh no", "error message")
+	})
+}
@@ -69,9 +69,19 @@ func (r *Registrar) applyToHandler(cfg *handlerCfg) {
 	cfg.Registrar = r
 }
 
+// Fully-qualified protobuf names for the supported versions of gRPC's server
+// reflection API. The versions have identical messages.
+const (
+	reflectionV1PackageFQN      = "grpc.reflection.v1"
+	reflectionV1AlphaPackageFQN = "grpc.reflection.v1alpha"
+)
+
 // NewReflectionHandler uses the information in the supplied Registrar to
 // construct an HTTP handler for gRPC's server reflection API. It returns the
-// HTTP handler and the correct path on which to mount it.
+// HTTP handler and the paths on which to mount it; mount the handler on each
+// path. The handler serves both grpc.reflection.v1.ServerReflection and the
+// older grpc.reflection.v1alpha.ServerReflection, so clients work regardless
+// of the version they prefer.
 //
 // Note that because the reflection API requires bidirectional streaming, the
 // returned handler only supports gRPC over HTTP/2 (i.e., it doesn't support
@@ -83,59 +93,48 @@ func (r *Registrar) applyToHandler(cfg *handlerCfg) {
 //   https://github.com/grpc/grpc-go/blob/master/Documentation/server-reflection-tutorial.md
 //   https://github.com/grpc/grpc/blob/master/doc/server-reflection.md
 //   https://github.com/fullstorydev/grpcurl
-func NewReflectionHandler(reg *Registrar) (string, http.Handler) {
-	const packageFQN = "grpc.reflection.v1alpha"
-	const serviceFQN = packageFQN + ".ServerReflection"
-	const methodFQN = serviceFQN + ".ServerReflectionInfo"
-	reg.register(serviceFQN)
-	h := NewHandler(
-		methodFQN,
-		serviceFQN,
-		packageFQN,
-		nil,               // no unary implementation
-		ServeTwirp(false), // no reflection in Twirp
-	)
+func NewReflectionHandler(reg *Registrar) ([]string, http.Handler) {
 	raw := &rawReflectionHandler{reg}
-	h.rawGRPC = raw.rawGRPC
-	httpHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h.Serve(w, r, nil)
-	})
-	return "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", httpHandler
+	mux := http.NewServeMux()
+	var paths []string
+	for _, packageFQN := range []string{reflectionV1PackageFQN, reflectionV1AlphaPackageFQN} {
+		serviceFQN := packageFQN + ".ServerReflection"
+		methodFQN := serviceFQN + ".ServerReflectionInfo"
+		reg.register(serviceFQN)
+		h := NewStreamingHandler(
+			StreamTypeBidirectional,
+			methodFQN,
+			serviceFQN,
+			packageFQN,
+			raw.stream,
+		)
+		path := fmt.Sprintf("/%s/ServerReflectionInfo", serviceFQN)
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			h.Serve(w, r, nil)
+		})
+		paths = append(paths, path)
+	}
+	return paths, mux
 }
 
 type rawReflectionHandler struct {
 	reg *Registrar
 }
 
-func (rh *rawReflectionHandler) rawGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, requestCompression, responseCompression string, hooks *Hooks) {
-	if r.ProtoMajor < 2 {
-		w.WriteHeader(http.StatusHTTPVersionNotSupported)
-		io.WriteString(w, "bidirectional streaming requires HTTP/2")
-		return
-	}
+func (rh *rawReflectionHandler) stream(_ context.Context, stream Stream) error {
 	for {
 		var req rpb.ServerReflectionRequest
-		if err := unmarshalLPM(r.Body, &req, requestCompression, 0); err != nil && errors.Is(err, io.EOF) {
-			writeErrorGRPC(ctx, w, nil, hooks)
-			return
+		if err := stream.Receive(&req); err != nil && errors.Is(err, io.EOF) {
+			return nil
 		} else if err != nil {
-			writeErrorGRPC(ctx, w, errorf(CodeUnknown, "can't unmarshal protobuf"), hooks)
-			return
+			return err
 		}
-
 		res, serr := rh.serve(&req)
 		if serr != nil {
-			writeErrorGRPC(ctx, w, serr, hooks)
-			return
+			return serr
 		}
-
-		if err := marshalLPM(ctx, w, res, responseCompression, 0, hooks); err != nil {
-			writeErrorGRPC(ctx, w, errorf(CodeUnknown, "can't marshal protobuf"), hooks)
-			return
-		}
-
-		if f, ok := w.(http.Flusher); ok {
-			f.Flush()
+		if err := stream.Send(res); err != nil {
+			return err
 		}
 	}
 }
@@ -2,15 +2,20 @@ package rerpc_test
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,6 +43,51 @@ func (p pingServer) Fail(ctx context.Context, req *pingpb.FailRequest) (*pingpb.
 	return nil, rerpc.Errorf(rerpc.Code(req.Code), errMsg)
 }
 
+func (p pingServer) Sum(ctx context.Context, stream *pingpb.PingService_SumServerReRPC) (*pingpb.SumResponse, error) {
+	var sum int64
+	for {
+		req, err := stream.Receive()
+		if errors.Is(err, io.EOF) {
+			return &pingpb.SumResponse{Sum: sum}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		sum += req.Number
+	}
+}
+
+func (p pingServer) CountUp(
+	ctx context.Context,
+	req *pingpb.CountUpRequest,
+	stream *pingpb.PingService_CountUpServerReRPC,
+) error {
+	if req.Number <= 0 {
+		return rerpc.Errorf(rerpc.CodeInvalidArgument, "number must be positive: got %v", req.Number)
+	}
+	for i := int64(1); i <= req.Number; i++ {
+		if err := stream.Send(&pingpb.CountUpResponse{Number: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p pingServer) CumSum(ctx context.Context, stream *pingpb.PingService_CumSumServerReRPC) error {
+	var sum int64
+	for {
+		req, err := stream.Receive()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		sum += req.Number
+		if err := stream.Send(&pingpb.CumSumResponse{Sum: sum}); err != nil {
+			return err
+		}
+	}
+}
+
 func TestHandlerTwirp(t *testing.T) {
 	mux := http.NewServeMux()
 	chain := rerpc.NewChain(rerpc.ClampTimeout(0, time.Minute))
@@ -128,6 +178,26 @@ func TestHandlerTwirp(t *testing.T) {
 			assertBodyEquals(t, bodyR, probe)
 		})
 
+		t.Run("gzip_refused", func(t *testing.T) {
+			probe := `{"number":"42"}`
+			r, err := http.NewRequest(
+				http.MethodPost,
+				fmt.Sprintf("%s/internal.ping.v1test.PingService/Ping", server.URL),
+				strings.NewReader(probe),
+			)
+			assert.Nil(t, err, "create request")
+			r.Header.Set("Content-Type", rerpc.TypeJSON)
+			r.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+
+			response, err := server.Client().Do(r)
+			assert.Nil(t, err, "make request")
+			testHeaders(t, response)
+			assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status code")
+			assert.Zero(t, response.Header.Get("Content-Encoding"), "content-encoding header")
+			assert.Equal(t, response.Header.Get("Vary"), "Accept-Encoding", "vary header")
+			assertBodyEquals(t, response.Body, probe)
+		})
+
 		t.Run("fail", func(t *testing.T) {
 			probe := fmt.Sprintf(`{"code":%d}`, rerpc.CodeResourceExhausted)
 			r, err := http.NewRequest(
@@ -274,7 +344,10 @@ func TestServerProtoGRPC(t *testing.T) {
 		reg,
 		chain,
 	))
-	mux.Handle(rerpc.NewReflectionHandler(reg))
+	paths, reflection := rerpc.NewReflectionHandler(reg)
+	for _, path := range paths {
+		mux.Handle(path, reflection)
+	}
 
 	testPing := func(t *testing.T, client pingpb.PingServiceClientReRPC) {
 		t.Run("ping", func(t *testing.T) {
@@ -299,6 +372,76 @@ func TestServerProtoGRPC(t *testing.T) {
 			assert.Zero(t, rerr.Details(), "error details")
 		})
 	}
+	testSum := func(t *testing.T, client pingpb.PingServiceClientReRPC) {
+		t.Run("sum", func(t *testing.T) {
+			const n = 10
+			stream, err := client.Sum(context.Background())
+			assert.Nil(t, err, "open stream")
+			var expect int64
+			for i := int64(1); i <= n; i++ {
+				expect += i
+				assert.Nil(t, stream.Send(&pingpb.SumRequest{Number: i}), "send %d", assert.Fmt(i))
+			}
+			res, err := stream.CloseAndReceive()
+			assert.Nil(t, err, "close and receive")
+			assert.Equal(t, res, &pingpb.SumResponse{Sum: expect}, "response")
+		})
+		t.Run("sum_empty", func(t *testing.T) {
+			stream, err := client.Sum(context.Background())
+			assert.Nil(t, err, "open stream")
+			res, err := stream.CloseAndReceive()
+			assert.Nil(t, err, "close and receive")
+			assert.Equal(t, res, &pingpb.SumResponse{}, "response")
+		})
+	}
+	testCumSum := func(t *testing.T, client pingpb.PingServiceClientReRPC) {
+		t.Run("cum_sum", func(t *testing.T) {
+			const n = 5
+			stream, err := client.CumSum(context.Background())
+			assert.Nil(t, err, "open stream")
+			defer stream.CloseReceive()
+			var expect int64
+			for i := int64(1); i <= n; i++ {
+				expect += i
+				assert.Nil(t, stream.Send(&pingpb.CumSumRequest{Number: i}), "send %d", assert.Fmt(i))
+				res, err := stream.Receive()
+				assert.Nil(t, err, "receive %d", assert.Fmt(i))
+				assert.Equal(t, res, &pingpb.CumSumResponse{Sum: expect}, "response %d", assert.Fmt(i))
+			}
+			assert.Nil(t, stream.CloseSend(), "close send")
+			_, err = stream.Receive()
+			assert.ErrorIs(t, err, io.EOF, "receive after close")
+			assert.Nil(t, stream.CloseReceive(), "close receive")
+		})
+	}
+	testCountUp := func(t *testing.T, client pingpb.PingServiceClientReRPC) {
+		t.Run("count_up", func(t *testing.T) {
+			const n = 5
+			stream, err := client.CountUp(context.Background(), &pingpb.CountUpRequest{Number: n})
+			assert.Nil(t, err, "open stream")
+			defer stream.Close()
+			var got []int64
+			for {
+				res, err := stream.Receive()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.Nil(t, err, "receive error")
+				got = append(got, res.Number)
+			}
+			assert.Equal(t, got, []int64{1, 2, 3, 4, 5}, "responses")
+			assert.Nil(t, stream.Close(), "close stream")
+		})
+		t.Run("count_up_error", func(t *testing.T) {
+			stream, err := client.CountUp(context.Background(), &pingpb.CountUpRequest{Number: -1})
+			assert.Nil(t, err, "open stream")
+			defer stream.Close()
+			res, err := stream.Receive()
+			assert.Nil(t, res, "response")
+			assert.NotNil(t, err, "receive error")
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeInvalidArgument, "error code")
+		})
+	}
 	testHealth := func(t *testing.T, url string, doer rerpc.Doer, opts ...rerpc.CallOption) {
 		t.Run("health", func(t *testing.T) {
 			const pingFQN = "internal.ping.v1test.PingService"
@@ -323,22 +466,6 @@ func TestServerProtoGRPC(t *testing.T) {
 				}
 				return res.(*healthpb.HealthCheckResponse), nil
 			}
-			callWatch := func(req *healthpb.HealthCheckRequest, opts ...rerpc.CallOption) (*healthpb.HealthCheckResponse, error) {
-				client := rerpc.NewClient(
-					doer,
-					url+"/grpc.health.v1.Health/Watch",
-					healthFQN+".Watch",
-					healthFQN,
-					healthPFQN,
-					func() proto.Message { return &healthpb.HealthCheckResponse{} },
-				)
-				res, err := client.Call(context.Background(), req, opts...)
-				if err != nil {
-					return nil, err
-				}
-				return res.(*healthpb.HealthCheckResponse), nil
-			}
-
 			t.Run("process", func(t *testing.T) {
 				req := &healthpb.HealthCheckRequest{}
 				res, err := callCheck(req, opts...)
@@ -360,22 +487,32 @@ func TestServerProtoGRPC(t *testing.T) {
 				assert.Equal(t, rerr.Code(), rerpc.CodeNotFound, "error code")
 			})
 			t.Run("watch", func(t *testing.T) {
-				req := &healthpb.HealthCheckRequest{Service: pingFQN}
-				_, err := callWatch(req, opts...)
-				assert.NotNil(t, err, "rpc error")
-				rerr, ok := rerpc.AsError(err)
-				assert.True(t, ok, "convert to rerpc error")
-				assert.Equal(t, rerr.Code(), rerpc.CodeUnimplemented, "error code")
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				stream := watchHealth(t, ctx, url, doer, pingFQN, opts...)
+				defer stream.CloseReceive()
+				res := &healthpb.HealthCheckResponse{}
+				assert.Nil(t, stream.Receive(res), "receive")
+				assert.Equal(t, rerpc.HealthStatus(res.Status), rerpc.HealthServing, "status")
+			})
+			t.Run("watch_unknown", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				stream := watchHealth(t, ctx, url, doer, unknown, opts...)
+				defer stream.CloseReceive()
+				res := &healthpb.HealthCheckResponse{}
+				assert.Nil(t, stream.Receive(res), "receive")
+				assert.Equal(t, rerpc.HealthStatus(res.Status), rerpc.HealthServiceUnknown, "status")
 			})
 		})
 	}
-	testReflection := func(t *testing.T, url string, doer rerpc.Doer, opts ...rerpc.CallOption) {
-		const reflectPFQN = "grpc.reflection.v1alpha"
-		const reflectSFQN = reflectPFQN + ".ServerReflection"
-		const reflectFQN = reflectSFQN + ".ServerReflectionInfo"
+	testReflection := func(t *testing.T, url string, doer rerpc.Doer, reflectPFQN string, opts ...rerpc.CallOption) {
+		reflectSFQN := reflectPFQN + ".ServerReflection"
+		reflectFQN := reflectSFQN + ".ServerReflectionInfo"
 		pingRequestFQN := string((&pingpb.PingRequest{}).ProtoReflect().Descriptor().FullName())
 		assert.Equal(t, reg.Services(), []string{
 			"grpc.health.v1.Health",
+			"grpc.reflection.v1.ServerReflection",
 			"grpc.reflection.v1alpha.ServerReflection",
 			"internal.ping.v1test.PingService",
 		}, "services registered in memory")
@@ -383,7 +520,7 @@ func TestServerProtoGRPC(t *testing.T) {
 		callReflect := func(req *reflectionpb.ServerReflectionRequest, opts ...rerpc.CallOption) (*reflectionpb.ServerReflectionResponse, error) {
 			client := rerpc.NewClient(
 				doer,
-				url+"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+				url+"/"+reflectSFQN+"/ServerReflectionInfo",
 				reflectFQN,
 				reflectSFQN,
 				reflectPFQN,
@@ -411,6 +548,7 @@ func TestServerProtoGRPC(t *testing.T) {
 					ListServicesResponse: &reflectionpb.ListServiceResponse{
 						Service: []*reflectionpb.ServiceResponse{
 							{Name: "grpc.health.v1.Health"},
+							{Name: "grpc.reflection.v1.ServerReflection"},
 							{Name: "grpc.reflection.v1alpha.ServerReflection"},
 							{Name: "internal.ping.v1test.PingService"},
 						},
@@ -494,12 +632,77 @@ func TestServerProtoGRPC(t *testing.T) {
 			}
 			assert.Equal(t, res, expect, "response")
 		})
+		t.Run("stream", func(t *testing.T) {
+			client := rerpc.NewStreamingClient(
+				rerpc.StreamTypeBidirectional,
+				doer,
+				url+"/"+reflectSFQN+"/ServerReflectionInfo",
+				reflectFQN,
+				reflectSFQN,
+				reflectPFQN,
+			)
+			stream, err := client.Stream(context.Background(), opts...)
+			assert.Nil(t, err, "open stream")
+			defer stream.CloseReceive()
+			for _, symbol := range []string{pingRequestFQN, "internal.ping.v1test.PingService"} {
+				req := &reflectionpb.ServerReflectionRequest{
+					Host: "some-host",
+					MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+						FileContainingSymbol: symbol,
+					},
+				}
+				assert.Nil(t, stream.Send(req), "send %s", assert.Fmt(symbol))
+				var res reflectionpb.ServerReflectionResponse
+				assert.Nil(t, stream.Receive(&res), "receive %s", assert.Fmt(symbol))
+				assert.Nil(t, res.GetErrorResponse(), "error in response")
+				assert.Equal(t, len(res.GetFileDescriptorResponse().GetFileDescriptorProto()), 1, "descriptors")
+			}
+			assert.Nil(t, stream.CloseSend(nil), "close send")
+			var res reflectionpb.ServerReflectionResponse
+			assert.ErrorIs(t, stream.Receive(&res), io.EOF, "receive after close")
+		})
+	}
+	testTwirp := func(t *testing.T, server *httptest.Server) {
+		for _, contentType := range []string{rerpc.TypeJSON, rerpc.TypeProtoTwirp} {
+			for _, gzip := range []bool{false, true} {
+				name := fmt.Sprintf("%s_gzip_%t", contentType, gzip)
+				t.Run(name, func(t *testing.T) {
+					client := pingpb.NewPingServiceClientReRPC(
+						server.URL,
+						server.Client(),
+						rerpc.CallTwirp(contentType),
+						rerpc.Gzip(gzip),
+						chain,
+					)
+					testPing(t, client)
+					testErrors(t, client)
+					t.Run("streaming_unimplemented", func(t *testing.T) {
+						_, err := client.CountUp(context.Background(), &pingpb.CountUpRequest{Number: 1})
+						assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnimplemented, "error code")
+					})
+				})
+			}
+		}
+		t.Run("bad_route", func(t *testing.T) {
+			client := rerpc.NewClient(
+				server.Client(),
+				server.URL+"/internal.ping.v1test.PingService/Missing",
+				"internal.ping.v1test.PingService.Missing",
+				"internal.ping.v1test.PingService",
+				"internal.ping.v1test",
+				func() proto.Message { return &pingpb.PingResponse{} },
+				rerpc.CallTwirp(rerpc.TypeJSON),
+			)
+			_, err := client.Call(context.Background(), &pingpb.PingRequest{})
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnimplemented, "error code")
+		})
 	}
 	testMatrix := func(t *testing.T, server *httptest.Server) {
 		t.Run("identity", func(t *testing.T) {
 			client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), chain)
 			testPing(t, client)
 			testErrors(t, client)
+			testCountUp(t, client)
 			testHealth(t, server.URL, server.Client())
 		})
 		t.Run("gzip", func(t *testing.T) {
@@ -511,6 +714,7 @@ func TestServerProtoGRPC(t *testing.T) {
 			)
 			testPing(t, client)
 			testErrors(t, client)
+			testCountUp(t, client)
 			testHealth(t, server.URL, server.Client(), rerpc.Gzip(true))
 		})
 	}
@@ -519,6 +723,31 @@ func TestServerProtoGRPC(t *testing.T) {
 		server := httptest.NewServer(mux)
 		defer server.Close()
 		testMatrix(t, server)
+		t.Run("twirp", func(t *testing.T) {
+			testTwirp(t, server)
+		})
+		t.Run("sum_requires_http2", func(t *testing.T) {
+			client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+			stream, err := client.Sum(context.Background())
+			assert.Nil(t, err, "open stream")
+			stream.Send(&pingpb.SumRequest{Number: 1}) // may fail if server has responded
+			_, err = stream.CloseAndReceive()
+			assert.NotNil(t, err, "close and receive")
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnimplemented, "error code")
+			assert.Match(t, err.Error(), "requires HTTP/2", "error message")
+		})
+		t.Run("cum_sum_requires_http2", func(t *testing.T) {
+			client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+			stream, err := client.CumSum(context.Background())
+			assert.Nil(t, err, "open stream")
+			defer stream.CloseReceive()
+			stream.Send(&pingpb.CumSumRequest{Number: 1}) // may fail if server has responded
+			assert.Nil(t, stream.CloseSend(), "close send")
+			_, err = stream.Receive()
+			assert.NotNil(t, err, "receive")
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnimplemented, "error code")
+			assert.Match(t, err.Error(), "bidirectional streaming requires HTTP/2", "error message")
+		})
 	})
 	t.Run("http2", func(t *testing.T) {
 		server := httptest.NewUnstartedServer(mux)
@@ -526,7 +755,16 @@ func TestServerProtoGRPC(t *testing.T) {
 		server.StartTLS()
 		defer server.Close()
 		testMatrix(t, server)
-		testReflection(t, server.URL, server.Client())
+		for _, reflectPFQN := range []string{"grpc.reflection.v1", "grpc.reflection.v1alpha"} {
+			t.Run(reflectPFQN, func(t *testing.T) {
+				testReflection(t, server.URL, server.Client(), reflectPFQN)
+			})
+		}
+		t.Run("streaming", func(t *testing.T) {
+			client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), rerpc.Gzip(true))
+			testSum(t, client)
+			testCumSum(t, client)
+		})
 	})
 }
 
@@ -621,3 +859,1244 @@ func TestCallMetadataIntegration(t *testing.T) {
 	assert.Nil(t, err, "call error")
 	assert.Equal(t, res, &pingpb.PingResponse{}, "call response")
 }
+
+// deflateCompressor is a minimal Compressor that counts its uses.
+type deflateCompressor struct {
+	compressed, decompressed int64
+}
+
+func (d *deflateCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	atomic.AddInt64(&d.compressed, 1)
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
+
+func (d *deflateCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	atomic.AddInt64(&d.decompressed, 1)
+	return flate.NewReader(r), nil
+}
+
+func TestCompressorIntegration(t *testing.T) {
+	const deflate = "deflate"
+	serverDeflate := &deflateCompressor{}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		pingServer{},
+		rerpc.RegisterCompressor(deflate, serverDeflate),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	assertUsed := func(t testing.TB, d *deflateCompressor, name string) {
+		t.Helper()
+		assert.True(t, atomic.LoadInt64(&d.compressed) > 0, name+" compressed")
+		assert.True(t, atomic.LoadInt64(&d.decompressed) > 0, name+" decompressed")
+	}
+	newClient := func(d *deflateCompressor, opts ...rerpc.CallOption) pingpb.PingServiceClientReRPC {
+		opts = append(
+			opts,
+			rerpc.RegisterCompressor(deflate, d),
+			rerpc.CallCompression(deflate),
+		)
+		return pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opts...)
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		clientDeflate := &deflateCompressor{}
+		client := newClient(clientDeflate)
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		assert.Nil(t, err, "ping error")
+		assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "ping response")
+		stream, err := client.CountUp(context.Background(), &pingpb.CountUpRequest{Number: 2})
+		assert.Nil(t, err, "open stream")
+		defer stream.Close()
+		for i := int64(1); i <= 2; i++ {
+			res, err := stream.Receive()
+			assert.Nil(t, err, "receive %d", assert.Fmt(i))
+			assert.Equal(t, res.Number, i, "response")
+		}
+		assertUsed(t, clientDeflate, "client")
+		assertUsed(t, serverDeflate, "server")
+	})
+	t.Run("twirp", func(t *testing.T) {
+		clientDeflate := &deflateCompressor{}
+		client := newClient(clientDeflate, rerpc.CallTwirp(rerpc.TypeProtoTwirp))
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		assert.Nil(t, err, "ping error")
+		assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "ping response")
+		assertUsed(t, clientDeflate, "client")
+	})
+	t.Run("unregistered_client", func(t *testing.T) {
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), rerpc.CallCompression(deflate))
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{})
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeInternal, "error code")
+	})
+	t.Run("unregistered_server", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle(pingpb.NewPingServiceHandlerReRPC(pingServer{}))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(
+			server.URL,
+			server.Client(),
+			rerpc.RegisterCompressor(deflate, &deflateCompressor{}),
+			rerpc.CallCompression(deflate),
+		)
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{})
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnimplemented, "error code")
+		assert.Match(t, err.Error(), "gzip,identity", "error message")
+	})
+}
+
+// headerDoer records the headers of the last response.
+type headerDoer struct {
+	doer   rerpc.Doer
+	header atomic.Value // http.Header
+}
+
+func (d *headerDoer) Do(r *http.Request) (*http.Response, error) {
+	res, err := d.doer.Do(r)
+	if err == nil {
+		d.header.Store(res.Header)
+	}
+	return res, err
+}
+
+func (d *headerDoer) Header() http.Header {
+	return d.header.Load().(http.Header)
+}
+
+func TestCompressMinBytesIntegration(t *testing.T) {
+	const (
+		deflate  = "deflate"
+		minBytes = 3
+	)
+	serverDeflate := &deflateCompressor{}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		pingServer{},
+		rerpc.RegisterCompressor(deflate, serverDeflate),
+		rerpc.CompressMinBytes(minBytes),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Pinging 42 marshals to 2 bytes, which is too small to compress, and 1<<20
+	// marshals to 4 bytes.
+	const small, large = 42, 1 << 20
+	testCompressMinBytes := func(t *testing.T, header, smallEncoding string, opts ...rerpc.CallOption) {
+		clientDeflate := &deflateCompressor{}
+		doer := &headerDoer{doer: server.Client()}
+		opts = append(
+			opts,
+			rerpc.RegisterCompressor(deflate, clientDeflate),
+			rerpc.CallCompression(deflate),
+			rerpc.CompressMinBytes(minBytes),
+		)
+		client := pingpb.NewPingServiceClientReRPC(server.URL, doer, opts...)
+		compressed := func(d *deflateCompressor) int64 {
+			return atomic.LoadInt64(&d.compressed)
+		}
+
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: small})
+		assert.Nil(t, err, "small ping error")
+		assert.Equal(t, res, &pingpb.PingResponse{Number: small}, "small ping response")
+		assert.Zero(t, compressed(clientDeflate), "client compressed small request")
+		assert.Zero(t, compressed(serverDeflate), "server compressed small response")
+		assert.Equal(t, doer.Header().Get(header), smallEncoding, "small ping "+header)
+
+		res, err = client.Ping(context.Background(), &pingpb.PingRequest{Number: large})
+		assert.Nil(t, err, "large ping error")
+		assert.Equal(t, res, &pingpb.PingResponse{Number: large}, "large ping response")
+		assert.Equal(t, compressed(clientDeflate), int64(1), "client compressed large request")
+		assert.Equal(t, compressed(serverDeflate), int64(1), "server compressed large response")
+		assert.Equal(t, doer.Header().Get(header), deflate, "large ping "+header)
+		atomic.StoreInt64(&serverDeflate.compressed, 0)
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		// gRPC handlers advertise the negotiated compression even if they don't
+		// compress any messages.
+		testCompressMinBytes(t, "Grpc-Encoding", deflate)
+	})
+	t.Run("twirp", func(t *testing.T) {
+		testCompressMinBytes(t, "Content-Encoding", "", rerpc.CallTwirp(rerpc.TypeProtoTwirp))
+	})
+}
+
+// flakyPingServer fails the first few pings with CodeUnavailable.
+type flakyPingServer struct {
+	pingServer
+
+	failures int64
+	attempts int64
+}
+
+func (p *flakyPingServer) Ping(ctx context.Context, req *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	atomic.AddInt64(&p.attempts, 1)
+	if atomic.AddInt64(&p.failures, -1) >= 0 {
+		return nil, rerpc.Errorf(rerpc.CodeUnavailable, errMsg)
+	}
+	return p.pingServer.Ping(ctx, req)
+}
+
+func TestRetryIntegration(t *testing.T) {
+	const method = "internal.ping.v1test.PingService.Ping"
+	newServer := func(failures int64, pushback string) (*httptest.Server, *flakyPingServer) {
+		ping := &flakyPingServer{failures: failures}
+		mux := http.NewServeMux()
+		mux.Handle(pingpb.NewPingServiceHandlerReRPC(ping))
+		handler := http.Handler(mux)
+		if pushback != "" {
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(http.TrailerPrefix+"Grpc-Retry-Pushback-Ms", pushback)
+				mux.ServeHTTP(w, r)
+			})
+		}
+		return httptest.NewServer(handler), ping
+	}
+	ping := func(t testing.TB, server *httptest.Server, opts ...rerpc.CallOption) error {
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opts...)
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		if err == nil {
+			assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "response")
+		}
+		return err
+	}
+	attempts := func(ping *flakyPingServer) int64 {
+		return atomic.LoadInt64(&ping.attempts)
+	}
+	fast := &rerpc.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	t.Run("success", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		assert.Nil(t, ping(t, server, fast), "ping error")
+		assert.Equal(t, attempts(flaky), int64(3), "attempts")
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		server, flaky := newServer(3, "")
+		defer server.Close()
+		err := ping(t, server, fast)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, attempts(flaky), int64(3), "attempts")
+	})
+	t.Run("twirp", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		assert.Nil(t, ping(t, server, fast, rerpc.CallTwirp(rerpc.TypeJSON)), "ping error")
+		assert.Equal(t, attempts(flaky), int64(3), "attempts")
+	})
+	t.Run("not_retryable", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		policy := *fast
+		policy.RetryableCodes = []rerpc.Code{rerpc.CodeResourceExhausted}
+		err := ping(t, server, &policy)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, attempts(flaky), int64(1), "attempts")
+	})
+	t.Run("method_override", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		noRetries := &rerpc.RetryPolicy{MaxAttempts: 1}
+		forMethod := *fast
+		forMethod.Methods = []string{method}
+		assert.Nil(t, ping(t, server, &forMethod, noRetries), "ping error")
+		assert.Equal(t, attempts(flaky), int64(3), "attempts")
+	})
+	t.Run("service_override", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		forService := *fast
+		forService.Methods = []string{"internal.ping.v1test.PingService"}
+		forMethod := rerpc.RetryPolicy{MaxAttempts: 1, Methods: []string{method}}
+		err := ping(t, server, &forMethod, &forService)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, attempts(flaky), int64(1), "attempts")
+	})
+	t.Run("pushback", func(t *testing.T) {
+		server, flaky := newServer(1, "1")
+		defer server.Close()
+		slow := rerpc.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}
+		start := time.Now()
+		assert.Nil(t, ping(t, server, &slow), "ping error")
+		assert.True(t, time.Since(start) < time.Minute, "pushback overrides backoff")
+		assert.Equal(t, attempts(flaky), int64(2), "attempts")
+	})
+	t.Run("pushback_stop", func(t *testing.T) {
+		server, flaky := newServer(2, "-1")
+		defer server.Close()
+		err := ping(t, server, fast)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, attempts(flaky), int64(1), "attempts")
+	})
+	t.Run("deadline", func(t *testing.T) {
+		server, flaky := newServer(2, "")
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		// Backoff is almost certainly longer than the timeout, so the client
+		// shouldn't bother waiting. Even if it does, the context expires before
+		// the next attempt.
+		slow := &rerpc.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+		start := time.Now()
+		_, err := client.Ping(ctx, &pingpb.PingRequest{}, slow)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.True(t, time.Since(start) < time.Minute, "respects deadline")
+		assert.Equal(t, attempts(flaky), int64(1), "attempts")
+	})
+}
+
+// slowPingServer hangs on the first ping until the client cancels it.
+type slowPingServer struct {
+	pingServer
+
+	attempts int64
+	canceled chan struct{}
+}
+
+func (p *slowPingServer) Ping(ctx context.Context, req *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	if atomic.AddInt64(&p.attempts, 1) == 1 {
+		<-ctx.Done()
+		close(p.canceled)
+		return nil, rerpc.Wrap(rerpc.CodeCanceled, ctx.Err())
+	}
+	return p.pingServer.Ping(ctx, req)
+}
+
+func TestHedgingIntegration(t *testing.T) {
+	newServer := func(svc pingpb.PingServiceReRPC) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.Handle(pingpb.NewPingServiceHandlerReRPC(svc))
+		return httptest.NewServer(mux)
+	}
+	slowHedge := &rerpc.HedgingPolicy{MaxAttempts: 2, Delay: time.Hour}
+
+	t.Run("slow", func(t *testing.T) {
+		slow := &slowPingServer{canceled: make(chan struct{})}
+		server := newServer(slow)
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(
+			server.URL,
+			server.Client(),
+			&rerpc.HedgingPolicy{MaxAttempts: 3, Delay: 10 * time.Millisecond},
+		)
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		assert.Nil(t, err, "ping error")
+		assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "ping response")
+		select {
+		case <-slow.canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("first attempt wasn't canceled")
+		}
+		assert.Equal(t, atomic.LoadInt64(&slow.attempts), int64(2), "attempts")
+	})
+	t.Run("non_fatal", func(t *testing.T) {
+		flaky := &flakyPingServer{failures: 1}
+		server := newServer(flaky)
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), slowHedge)
+		// The first attempt fails with a non-fatal code, so the client sends the
+		// second attempt immediately.
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{})
+		assert.Nil(t, err, "ping error")
+		assert.Equal(t, atomic.LoadInt64(&flaky.attempts), int64(2), "attempts")
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		flaky := &flakyPingServer{failures: 5}
+		server := newServer(flaky)
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(
+			server.URL,
+			server.Client(),
+			&rerpc.HedgingPolicy{MaxAttempts: 3},
+		)
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{})
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, atomic.LoadInt64(&flaky.attempts), int64(3), "attempts")
+	})
+	t.Run("fatal", func(t *testing.T) {
+		server := newServer(pingServer{})
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), slowHedge)
+		// Fail isn't marked idempotent in the protobuf definition.
+		_, err := client.Fail(
+			context.Background(),
+			&pingpb.FailRequest{Code: int32(rerpc.CodeResourceExhausted)},
+			rerpc.CallIdempotent(true),
+		)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+	})
+	t.Run("not_idempotent", func(t *testing.T) {
+		flaky := &flakyPingServer{failures: 1}
+		server := newServer(flaky)
+		defer server.Close()
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), slowHedge)
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{}, rerpc.CallIdempotent(false))
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+		assert.Equal(t, atomic.LoadInt64(&flaky.attempts), int64(1), "attempts")
+	})
+}
+
+func TestBalancerIntegration(t *testing.T) {
+	newServer := func(svc pingpb.PingServiceReRPC) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.Handle(pingpb.NewPingServiceHandlerReRPC(svc))
+		return httptest.NewServer(mux)
+	}
+	down := &flakyPingServer{failures: 1 << 20}
+	unhealthy, healthy := newServer(down), newServer(pingServer{})
+	defer unhealthy.Close()
+	defer healthy.Close()
+	balancer := &rerpc.Balancer{
+		Resolver: rerpc.StaticResolver{unhealthy.URL, healthy.URL},
+		Doer:     healthy.Client(),
+	}
+	client := pingpb.NewPingServiceClientReRPC("http://ping", balancer)
+	// The unhealthy replica is ejected after its first failure.
+	var failures int
+	for i := 0; i < 5; i++ {
+		res, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		if err != nil {
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "error code")
+			failures++
+			continue
+		}
+		assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "ping response")
+	}
+	assert.Equal(t, failures, 1, "failures")
+	assert.Equal(t, atomic.LoadInt64(&down.attempts), int64(1), "unhealthy attempts")
+}
+
+func TestCircuitBreakerIntegration(t *testing.T) {
+	flaky := &flakyPingServer{failures: 2}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(flaky))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	hooks := &rerpc.Hooks{
+		OnCircuitStateChange: func(_ context.Context, name string, from, to rerpc.CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, from, to))
+		},
+	}
+	breaker := &rerpc.CircuitBreaker{MinRequests: 2, OpenTimeout: 50 * time.Millisecond}
+	client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), breaker, hooks)
+	ping := func() error {
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		return err
+	}
+
+	// Calls that never reach the server don't count.
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		_, err := client.Ping(expired, &pingpb.PingRequest{Number: 42})
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeDeadlineExceeded, "expired deadline")
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, rerpc.CodeOf(ping()), rerpc.CodeUnavailable, "server error")
+	}
+	err := ping()
+	assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "fail fast")
+	assert.Match(t, err.Error(), "circuit breaker", "error message")
+	assert.Equal(t, atomic.LoadInt64(&flaky.attempts), int64(2), "attempts")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, ping(), "probe")
+	assert.Nil(t, ping(), "closed")
+	const method = "internal.ping.v1test.PingService.Ping"
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, changes, []string{
+		method + ": closed -> open",
+		method + ": open -> half-open",
+		method + ": half-open -> closed",
+	}, "state changes")
+}
+
+// gatedPingServer blocks pings until the test releases them.
+type gatedPingServer struct {
+	pingServer
+
+	attempts int64
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (p *gatedPingServer) Ping(ctx context.Context, req *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	atomic.AddInt64(&p.attempts, 1)
+	p.started <- struct{}{}
+	<-p.release
+	return p.pingServer.Ping(ctx, req)
+}
+
+func TestConcurrencyLimiterIntegration(t *testing.T) {
+	gated := &gatedPingServer{started: make(chan struct{}), release: make(chan struct{})}
+	var rejected int64
+	observe := rerpc.InterceptorFunc(func(next rerpc.Func) rerpc.Func {
+		return rerpc.Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
+			res, err := next(ctx, req)
+			if rerpc.CodeOf(err) == rerpc.CodeResourceExhausted {
+				atomic.AddInt64(&rejected, 1)
+			}
+			return res, err
+		})
+	})
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		gated,
+		&rerpc.ConcurrencyLimiter{Limit: 1},
+		rerpc.NewChain(observe),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+
+	// Occupy the only slot.
+	done := make(chan error)
+	go func() {
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		done <- err
+	}()
+	<-gated.started
+
+	_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+	assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "gRPC error code")
+	assert.Match(t, err.Error(), "too many concurrent requests", "error message")
+
+	request, err := http.NewRequest(
+		http.MethodPost,
+		server.URL+"/internal.ping.v1test.PingService/Ping",
+		strings.NewReader(`{"number": "42"}`),
+	)
+	assert.Nil(t, err, "create request")
+	request.Header.Set("Content-Type", rerpc.TypeJSON)
+	response, err := server.Client().Do(request)
+	assert.Nil(t, err, "make Twirp request")
+	response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusTooManyRequests, "Twirp status")
+	assert.Equal(t, atomic.LoadInt64(&gated.attempts), int64(1), "rejected before reaching implementation")
+	assert.Equal(t, atomic.LoadInt64(&rejected), int64(2), "interceptor saw rejections")
+
+	close(gated.release)
+	assert.Nil(t, <-done, "first ping")
+	// The first call releases its slot just after responding.
+	go func() {
+		for range gated.started {
+		}
+	}()
+	defer close(gated.started)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		if err == nil {
+			break
+		}
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+		assert.True(t, time.Now().Before(deadline), "slot released")
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRateLimiterIntegration(t *testing.T) {
+	limiter := &rerpc.RateLimiter{
+		Rate: 0.001,
+		Key: func(ctx context.Context) string {
+			md, _ := rerpc.HandlerMeta(ctx)
+			return md.Request().Get("Api-Key")
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(pingServer{}, rerpc.NewChain(limiter)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ping := func(t testing.TB, key string, opts ...rerpc.CallOption) error {
+		t.Helper()
+		setKey := rerpc.InterceptorFunc(func(next rerpc.Func) rerpc.Func {
+			return rerpc.Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				md, _ := rerpc.CallMeta(ctx)
+				md.Request().Set("Api-Key", key)
+				return next(ctx, req)
+			})
+		})
+		opts = append(opts, rerpc.NewChain(setKey))
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opts...)
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		return err
+	}
+
+	assert.Nil(t, ping(t, "foo"), "first call")
+	for _, opts := range [][]rerpc.CallOption{nil, {rerpc.CallTwirp(rerpc.TypeJSON)}} {
+		err := ping(t, "foo", opts...)
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+		if len(opts) == 0 {
+			// Twirp doesn't support error details.
+			rerr, ok := rerpc.AsError(err)
+			assert.True(t, ok, "rerpc error")
+			details := rerr.Details()
+			assert.Equal(t, len(details), 1, "details")
+			assert.Equal(t, details[0].TypeUrl, "type.googleapis.com/google.rpc.RetryInfo", "type URL")
+		}
+	}
+	assert.Nil(t, ping(t, "bar"), "other key")
+}
+
+func TestContextErrorIntegration(t *testing.T) {
+	// The server sends response headers and part of a message, then stalls
+	// until the client gives up.
+	written := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte{0, 0}) // truncated gRPC prefix, or truncated JSON
+		w.(http.Flusher).Flush()
+		written <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	call := func(ctx context.Context, opts ...rerpc.CallOption) error {
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opts...)
+		_, err := client.Ping(ctx, &pingpb.PingRequest{})
+		return err
+	}
+	stream := func(ctx context.Context, opts ...rerpc.CallOption) error {
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opts...)
+		s, err := client.CountUp(ctx, &pingpb.CountUpRequest{Number: 1})
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		_, err = s.Receive()
+		return err
+	}
+	for _, tt := range []struct {
+		name string
+		do   func(context.Context, ...rerpc.CallOption) error
+		opts []rerpc.CallOption
+	}{
+		{"grpc", call, nil},
+		{"twirp", call, []rerpc.CallOption{rerpc.CallTwirp(rerpc.TypeJSON)}},
+		{"stream", stream, nil},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("canceled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					<-written
+					cancel()
+				}()
+				err := tt.do(ctx, tt.opts...)
+				assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeCanceled, "error code")
+				assert.True(t, errors.Is(err, context.Canceled), "wraps context error")
+			})
+			t.Run("deadline", func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				go func() { <-written }()
+				err := tt.do(ctx, tt.opts...)
+				assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeDeadlineExceeded, "error code")
+			})
+		})
+	}
+}
+
+// forwardingPingServer forwards pings to another PingService.
+type forwardingPingServer struct {
+	pingServer
+
+	client pingpb.PingServiceClientReRPC
+}
+
+func (p *forwardingPingServer) Ping(ctx context.Context, req *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	return p.client.Ping(ctx, req)
+}
+
+// recordingPingServer records the headers and remaining timeout of each ping.
+type recordingPingServer struct {
+	pingServer
+
+	mu      sync.Mutex
+	header  http.Header
+	timeout time.Duration
+}
+
+func (p *recordingPingServer) Ping(ctx context.Context, req *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	md, _ := rerpc.HandlerMeta(ctx)
+	deadline, _ := ctx.Deadline()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.header = md.Request().Clone()
+	p.timeout = time.Until(deadline)
+	return p.pingServer.Ping(ctx, req)
+}
+
+func TestPropagationIntegration(t *testing.T) {
+	newServer := func(svc pingpb.PingServiceReRPC) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.Handle(pingpb.NewPingServiceHandlerReRPC(svc))
+		return httptest.NewServer(mux)
+	}
+	backend := &recordingPingServer{}
+	backendServer := newServer(backend)
+	defer backendServer.Close()
+	frontendServer := newServer(&forwardingPingServer{
+		client: pingpb.NewPingServiceClientReRPC(
+			backendServer.URL,
+			backendServer.Client(),
+			&rerpc.Propagation{
+				Headers:        []string{"Trace-Id", "Tenant-Id"},
+				DeadlineMargin: time.Minute,
+			},
+		),
+	})
+	defer frontendServer.Close()
+
+	setHeaders := rerpc.InterceptorFunc(func(next rerpc.Func) rerpc.Func {
+		return rerpc.Func(func(ctx context.Context, req proto.Message) (proto.Message, error) {
+			md, _ := rerpc.CallMeta(ctx)
+			md.Request().Set("Trace-Id", "abc")
+			md.Request().Set("Tenant-Id", "acme")
+			md.Request().Set("Authorization", "Bearer hunter2")
+			return next(ctx, req)
+		})
+	})
+	client := pingpb.NewPingServiceClientReRPC(
+		frontendServer.URL,
+		frontendServer.Client(),
+		rerpc.NewChain(setHeaders),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	res, err := client.Ping(ctx, &pingpb.PingRequest{Number: 42})
+	assert.Nil(t, err, "ping error")
+	assert.Equal(t, res, &pingpb.PingResponse{Number: 42}, "ping response")
+	backend.mu.Lock()
+	assert.Equal(t, backend.header.Get("Trace-Id"), "abc", "trace ID")
+	assert.Equal(t, backend.header.Get("Tenant-Id"), "acme", "tenant")
+	assert.Zero(t, backend.header.Get("Authorization"), "headers not in allow list")
+	assert.True(t, backend.timeout <= 59*time.Minute, "deadline reduced by margin")
+	assert.True(t, backend.timeout > 58*time.Minute, "deadline propagated")
+	backend.mu.Unlock()
+
+	// If the margin leaves no time, the frontend doesn't call the backend.
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = client.Ping(ctx, &pingpb.PingRequest{Number: 42})
+	assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeDeadlineExceeded, "no time left")
+}
+
+func TestTwirpTimeoutIntegration(t *testing.T) {
+	recorder := &recordingPingServer{}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		recorder,
+		rerpc.NewChain(rerpc.ClampTimeout(0, time.Hour)),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	timeout := func() time.Duration {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return recorder.timeout
+	}
+	post := func(t testing.TB, header http.Header) *http.Response {
+		t.Helper()
+		request, err := http.NewRequest(
+			http.MethodPost,
+			server.URL+"/internal.ping.v1test.PingService/Ping",
+			strings.NewReader(`{"number": "42"}`),
+		)
+		assert.Nil(t, err, "create request")
+		request.Header = header
+		request.Header.Set("Content-Type", rerpc.TypeJSON)
+		response, err := server.Client().Do(request)
+		assert.Nil(t, err, "make request")
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		return response
+	}
+
+	t.Run("header", func(t *testing.T) {
+		response := post(t, http.Header{"Rerpc-Timeout-Ms": {"1500"}})
+		assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status")
+		assert.True(t, timeout() <= 1500*time.Millisecond, "timeout applied")
+		assert.True(t, timeout() > time.Second, "timeout applied")
+	})
+	t.Run("grpc_header", func(t *testing.T) {
+		response := post(t, http.Header{"Grpc-Timeout": {"2S"}})
+		assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status")
+		assert.True(t, timeout() <= 2*time.Second, "timeout applied")
+		assert.True(t, timeout() > time.Second, "timeout applied")
+	})
+	t.Run("precedence", func(t *testing.T) {
+		response := post(t, http.Header{"Grpc-Timeout": {"2S"}, "Rerpc-Timeout-Ms": {"500"}})
+		assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status")
+		assert.True(t, timeout() <= 500*time.Millisecond, "reRPC header wins")
+	})
+	t.Run("clamped", func(t *testing.T) {
+		response := post(t, make(http.Header))
+		assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status")
+		assert.True(t, timeout() <= time.Hour, "clamped to max")
+		assert.True(t, timeout() > 59*time.Minute, "clamped to max")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		response := post(t, http.Header{"Rerpc-Timeout-Ms": {"soon"}})
+		assert.Equal(t, response.StatusCode, http.StatusBadRequest, "HTTP status")
+	})
+	t.Run("client", func(t *testing.T) {
+		client := pingpb.NewPingServiceClientReRPC(
+			server.URL,
+			server.Client(),
+			rerpc.CallTwirp(rerpc.TypeJSON),
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, err := client.Ping(ctx, &pingpb.PingRequest{Number: 42})
+		assert.Nil(t, err, "ping error")
+		assert.True(t, timeout() <= time.Minute, "timeout sent")
+		assert.True(t, timeout() > 59*time.Second, "timeout sent")
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Zero(t, recorder.header.Get("Grpc-Timeout"), "no gRPC timeout")
+		assert.NotZero(t, recorder.header.Get("Rerpc-Timeout-Ms"), "reRPC timeout")
+	})
+}
+
+func TestReadMaxBytesIntegration(t *testing.T) {
+	const maxBytes = 4096
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		pingServer{},
+		rerpc.ReadMaxBytes(maxBytes),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// A megabyte of zeros compresses to about a kilobyte, well under the
+	// compressed limit.
+	bomb := &bytes.Buffer{}
+	gw := gzip.NewWriter(bomb)
+	gw.Write(make([]byte, 1<<20))
+	gw.Close()
+	assert.True(t, bomb.Len() < maxBytes, "bomb is small")
+	post := func(t testing.TB, body []byte, header http.Header) *http.Response {
+		t.Helper()
+		request, err := http.NewRequest(
+			http.MethodPost,
+			server.URL+"/internal.ping.v1test.PingService/Ping",
+			bytes.NewReader(body),
+		)
+		assert.Nil(t, err, "create request")
+		request.Header = header
+		response, err := server.Client().Do(request)
+		assert.Nil(t, err, "make request")
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		return response
+	}
+
+	t.Run("grpc_bomb", func(t *testing.T) {
+		body := make([]byte, 5, 5+bomb.Len())
+		body[0] = 1 // compressed
+		binary.BigEndian.PutUint32(body[1:], uint32(bomb.Len()))
+		body = append(body, bomb.Bytes()...)
+		response := post(t, body, http.Header{
+			"Content-Type":  {rerpc.TypeDefaultGRPC},
+			"Grpc-Encoding": {"gzip"},
+		})
+		assert.Equal(t, response.StatusCode, http.StatusOK, "HTTP status")
+		assert.Equal(t, response.Trailer.Get("Grpc-Status"), "8", "gRPC status")
+	})
+	t.Run("twirp_bomb", func(t *testing.T) {
+		response := post(t, bomb.Bytes(), http.Header{
+			"Content-Type":     {rerpc.TypeProtoTwirp},
+			"Content-Encoding": {"gzip"},
+		})
+		assert.Equal(t, response.StatusCode, http.StatusTooManyRequests, "HTTP status")
+	})
+	t.Run("twirp_oversized", func(t *testing.T) {
+		response := post(t, make([]byte, 2*maxBytes), http.Header{
+			"Content-Type": {rerpc.TypeProtoTwirp},
+		})
+		assert.Equal(t, response.StatusCode, http.StatusTooManyRequests, "HTTP status")
+	})
+	t.Run("client", func(t *testing.T) {
+		for _, opt := range []rerpc.CallOption{rerpc.Gzip(false), rerpc.CallTwirp(rerpc.TypeProtoTwirp)} {
+			client := pingpb.NewPingServiceClientReRPC(
+				server.URL,
+				server.Client(),
+				rerpc.ReadMaxBytes(1),
+				opt,
+			)
+			_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+			assert.Match(t, err.Error(), "message too large", "error message")
+		}
+	})
+}
+
+// countingDoer counts the requests it sends.
+type countingDoer struct {
+	doer  rerpc.Doer
+	count int64
+}
+
+func (d *countingDoer) Do(r *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&d.count, 1)
+	return d.doer.Do(r)
+}
+
+func TestWriteMaxBytesIntegration(t *testing.T) {
+	// Pinging 1<<20 marshals to 4 bytes, and empty messages marshal to at most
+	// 2 bytes ("{}" in JSON). Limits apply after compression, so disable
+	// compression to keep small messages small.
+	const maxBytes, large = 2, 1 << 20
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(
+		pingServer{},
+		rerpc.WriteMaxBytes(maxBytes),
+		rerpc.Gzip(false),
+	))
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	protocols := []rerpc.CallOption{
+		rerpc.Gzip(false),
+		rerpc.CallTwirp(rerpc.TypeJSON),
+		rerpc.CallTwirp(rerpc.TypeProtoTwirp),
+	}
+
+	t.Run("client", func(t *testing.T) {
+		for _, opt := range protocols {
+			doer := &countingDoer{doer: server.Client()}
+			client := pingpb.NewPingServiceClientReRPC(server.URL, doer, rerpc.WriteMaxBytes(maxBytes), opt)
+			_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: large})
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+			assert.Match(t, err.Error(), "message too large", "error message")
+			assert.Zero(t, atomic.LoadInt64(&doer.count), "requests sent")
+		}
+	})
+	t.Run("client_stream", func(t *testing.T) {
+		doer := &countingDoer{doer: server.Client()}
+		client := pingpb.NewPingServiceClientReRPC(server.URL, doer, rerpc.WriteMaxBytes(maxBytes))
+		stream, err := client.Sum(context.Background())
+		assert.Nil(t, err, "open stream")
+		err = stream.Send(&pingpb.SumRequest{Number: large})
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+		assert.Zero(t, atomic.LoadInt64(&doer.count), "requests sent")
+		// Small messages still fit.
+		assert.Nil(t, stream.Send(&pingpb.SumRequest{Number: 0}), "send empty message")
+		res, err := stream.CloseAndReceive()
+		assert.Nil(t, err, "close and receive")
+		assert.Zero(t, res.Sum, "sum")
+	})
+	t.Run("handler", func(t *testing.T) {
+		for _, opt := range protocols {
+			client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client(), opt)
+			_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: large})
+			assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+			assert.Match(t, err.Error(), "message too large", "error message")
+			res, err := client.Ping(context.Background(), &pingpb.PingRequest{})
+			assert.Nil(t, err, "empty ping error")
+			assert.Zero(t, res.Number, "empty ping response")
+		}
+	})
+	t.Run("handler_stream", func(t *testing.T) {
+		client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+		// Numbers up to 127 marshal to 2 bytes, and larger numbers don't fit.
+		stream, err := client.CountUp(context.Background(), &pingpb.CountUpRequest{Number: 200})
+		assert.Nil(t, err, "open stream")
+		defer stream.Close()
+		var last int64
+		for {
+			res, err := stream.Receive()
+			if err != nil {
+				assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeResourceExhausted, "error code")
+				break
+			}
+			last = res.Number
+		}
+		assert.Equal(t, last, int64(127), "last response")
+	})
+}
+
+// watchHealth opens a stream to the health service's Watch method.
+func watchHealth(t testing.TB, ctx context.Context, url string, doer rerpc.Doer, service string, opts ...rerpc.CallOption) rerpc.Stream {
+	t.Helper()
+	client := rerpc.NewStreamingClient(
+		rerpc.StreamTypeServer,
+		doer,
+		url+"/grpc.health.v1.Health/Watch",
+		"grpc.health.v1.Health.Watch",
+		"grpc.health.v1.Health",
+		"grpc.health.v1",
+		opts...,
+	)
+	stream, err := client.Stream(ctx)
+	assert.Nil(t, err, "open stream")
+	assert.Nil(t, stream.Send(&healthpb.HealthCheckRequest{Service: service}), "send request")
+	assert.Nil(t, stream.CloseSend(nil), "close send")
+	return stream
+}
+
+// healthSwitch is a health-checking function and HealthNotifier with a single
+// mutable status.
+type healthSwitch struct {
+	mu      sync.Mutex
+	status  rerpc.HealthStatus
+	changed chan struct{}
+}
+
+func newHealthSwitch() *healthSwitch {
+	return &healthSwitch{status: rerpc.HealthServing, changed: make(chan struct{})}
+}
+
+func (h *healthSwitch) Check(context.Context, string) (rerpc.HealthStatus, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status, nil
+}
+
+func (h *healthSwitch) Changed() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.changed
+}
+
+func (h *healthSwitch) Set(status rerpc.HealthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = status
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+func TestHealthWatchIntegration(t *testing.T) {
+	health := newHealthSwitch()
+	mux := http.NewServeMux()
+	mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := watchHealth(t, ctx, server.URL, server.Client(), "")
+	defer stream.CloseReceive()
+	receive := func(t testing.TB) rerpc.HealthStatus {
+		t.Helper()
+		res := &healthpb.HealthCheckResponse{}
+		assert.Nil(t, stream.Receive(res), "receive")
+		return rerpc.HealthStatus(res.Status)
+	}
+
+	assert.Equal(t, receive(t), rerpc.HealthServing, "initial status")
+	health.Set(rerpc.HealthServing) // unchanged, so not sent
+	health.Set(rerpc.HealthNotServing)
+	assert.Equal(t, receive(t), rerpc.HealthNotServing, "updated status")
+	health.Set(rerpc.HealthServing)
+	assert.Equal(t, receive(t), rerpc.HealthServing, "restored status")
+
+	// Watch is a streaming method, so it doesn't support Twirp.
+	response, err := server.Client().Post(
+		server.URL+"/grpc.health.v1.Health/Watch",
+		rerpc.TypeJSON,
+		strings.NewReader("{}"),
+	)
+	assert.Nil(t, err, "post JSON")
+	response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusUnsupportedMediaType, "HTTP status")
+}
+
+func TestHealthServerIntegration(t *testing.T) {
+	const pingFQN = "internal.ping.v1test.PingService"
+	reg := rerpc.NewRegistrar()
+	health := rerpc.NewHealthServer(reg)
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(pingServer{}, reg))
+	mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health, reg))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := watchHealth(t, ctx, server.URL, server.Client(), pingFQN)
+	defer stream.CloseReceive()
+	receive := func(t testing.TB) rerpc.HealthStatus {
+		t.Helper()
+		res := &healthpb.HealthCheckResponse{}
+		assert.Nil(t, stream.Receive(res), "receive")
+		return rerpc.HealthStatus(res.Status)
+	}
+
+	assert.Equal(t, receive(t), rerpc.HealthServing, "initial status")
+	health.SetStatus(pingFQN, rerpc.HealthNotServing)
+	assert.Equal(t, receive(t), rerpc.HealthNotServing, "after SetStatus")
+	health.SetStatus(pingFQN, rerpc.HealthServing)
+	assert.Equal(t, receive(t), rerpc.HealthServing, "after second SetStatus")
+	health.Shutdown()
+	assert.Equal(t, receive(t), rerpc.HealthNotServing, "after Shutdown")
+	health.Resume()
+	assert.Equal(t, receive(t), rerpc.HealthServing, "after Resume")
+}
+
+func TestHealthAggregatorIntegration(t *testing.T) {
+	// The downstream server's health is set by hand.
+	downstreamHealth := rerpc.NewHealthServer(nil)
+	downstreamMux := http.NewServeMux()
+	downstreamMux.Handle(rerpc.NewNotifyingHealthHandler(downstreamHealth.Check, downstreamHealth))
+	downstream := httptest.NewServer(downstreamMux)
+	defer downstream.Close()
+
+	// The upstream server depends on the downstream server.
+	health := &rerpc.HealthAggregator{
+		Probes: []rerpc.HealthProbe{{
+			Name:     "downstream",
+			Check:    rerpc.RemoteHealthCheck(downstream.Client(), downstream.URL, ""),
+			Interval: 5 * time.Millisecond,
+		}},
+	}
+	defer health.Close()
+	mux := http.NewServeMux()
+	mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health))
+	mux.Handle("/readyz", health)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := watchHealth(t, ctx, server.URL, server.Client(), "")
+	defer stream.CloseReceive()
+	receive := func(t testing.TB) rerpc.HealthStatus {
+		t.Helper()
+		res := &healthpb.HealthCheckResponse{}
+		assert.Nil(t, stream.Receive(res), "receive")
+		return rerpc.HealthStatus(res.Status)
+	}
+	ready := func(t testing.TB) int {
+		t.Helper()
+		response, err := server.Client().Get(server.URL + "/readyz")
+		assert.Nil(t, err, "get readiness")
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	assert.Equal(t, receive(t), rerpc.HealthServing, "initial status")
+	assert.Equal(t, ready(t), http.StatusOK, "initial readiness")
+	downstreamHealth.SetStatus("", rerpc.HealthNotServing)
+	assert.Equal(t, receive(t), rerpc.HealthNotServing, "downstream not serving")
+	assert.Equal(t, ready(t), http.StatusServiceUnavailable, "readiness while downstream not serving")
+	downstreamHealth.SetStatus("", rerpc.HealthServing)
+	assert.Equal(t, receive(t), rerpc.HealthServing, "downstream serving again")
+}
+
+func TestDrainerIntegration(t *testing.T) {
+	const pingFQN = "internal.ping.v1test.PingService"
+	gated := &gatedPingServer{started: make(chan struct{}), release: make(chan struct{})}
+	reg := rerpc.NewRegistrar()
+	health := rerpc.NewHealthServer(reg)
+	// Leave the Server nil, so the test can observe rejected calls over the
+	// network.
+	drainer := &rerpc.Drainer{Health: health}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(gated, reg, drainer))
+	mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health, reg))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+		done <- err
+	}()
+	<-gated.started
+	shutdown := make(chan error)
+	go func() { shutdown <- drainer.Shutdown(context.Background()) }()
+
+	// Fail isn't gated, so poll it until the drainer starts rejecting calls.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := client.Fail(context.Background(), &pingpb.FailRequest{Code: int32(rerpc.CodeInternal)})
+		if rerpc.CodeOf(err) == rerpc.CodeUnavailable {
+			assert.Match(t, err.Error(), "server is shutting down", "error message")
+			break
+		}
+		assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeInternal, "error code")
+		assert.True(t, time.Now().Before(deadline), "drainer rejects calls")
+		time.Sleep(time.Millisecond)
+	}
+	status, err := health.Check(context.Background(), pingFQN)
+	assert.Nil(t, err, "check health")
+	assert.Equal(t, status, rerpc.HealthNotServing, "health while draining")
+
+	_, err = client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+	assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "gRPC error code")
+	request, err := http.NewRequest(
+		http.MethodPost,
+		server.URL+"/internal.ping.v1test.PingService/Ping",
+		strings.NewReader(`{"number": "42"}`),
+	)
+	assert.Nil(t, err, "create request")
+	request.Header.Set("Content-Type", rerpc.TypeJSON)
+	response, err := server.Client().Do(request)
+	assert.Nil(t, err, "make Twirp request")
+	response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusServiceUnavailable, "Twirp status")
+	assert.Equal(t, atomic.LoadInt64(&gated.attempts), int64(1), "rejected before reaching implementation")
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with a call in flight", err)
+	default:
+	}
+	close(gated.release)
+	assert.Nil(t, <-done, "in-flight ping")
+	assert.Nil(t, <-shutdown, "shutdown")
+}
+
+func TestDrainerHealthAggregatorIntegration(t *testing.T) {
+	health := &rerpc.HealthAggregator{
+		Probes: []rerpc.HealthProbe{{
+			Name:  "db",
+			Check: func(context.Context) error { return nil },
+		}},
+	}
+	defer health.Close()
+	drainer := &rerpc.Drainer{Health: health}
+	mux := http.NewServeMux()
+	mux.Handle(pingpb.NewPingServiceHandlerReRPC(pingServer{}, drainer))
+	mux.Handle(rerpc.NewNotifyingHealthHandler(health.Check, health))
+	mux.Handle("/readyz", health)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := watchHealth(t, ctx, server.URL, server.Client(), "")
+	defer stream.CloseReceive()
+	res := &healthpb.HealthCheckResponse{}
+	assert.Nil(t, stream.Receive(res), "receive")
+	assert.Equal(t, rerpc.HealthStatus(res.Status), rerpc.HealthServing, "initial status")
+
+	assert.Nil(t, drainer.Shutdown(context.Background()), "shutdown")
+	assert.Nil(t, stream.Receive(res), "receive")
+	assert.Equal(t, rerpc.HealthStatus(res.Status), rerpc.HealthNotServing, "status after shutdown")
+	response, err := server.Client().Get(server.URL + "/readyz")
+	assert.Nil(t, err, "get readiness")
+	response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusServiceUnavailable, "readiness after shutdown")
+	client := pingpb.NewPingServiceClientReRPC(server.URL, server.Client())
+	_, err = client.Ping(context.Background(), &pingpb.PingRequest{Number: 42})
+	assert.Equal(t, rerpc.CodeOf(err), rerpc.CodeUnavailable, "ping after shutdown")
+}
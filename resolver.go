@@ -0,0 +1,250 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for the zero values of resolvers' polling intervals.
+const (
+	defaultDNSInterval  = 30 * time.Second
+	defaultFileInterval = time.Second
+)
+
+// A Resolver discovers the base URLs of a service's replicas (for example,
+// "https://10.0.0.1:8443") and watches for changes. Balancers use Resolvers to
+// decide where to send requests, so long-lived clients pick up new and
+// removed replicas without being rebuilt.
+//
+// Watch reports the complete set of base URLs to the supplied function, first
+// as soon as possible and then whenever the set changes. If resolution fails,
+// Watch reports the error instead; callers should keep using the last set of
+// URLs. Watch blocks until the context is canceled, and it must not call the
+// supplied function concurrently or after returning. Implementations must be
+// safe to use from multiple goroutines.
+type Resolver interface {
+	Watch(ctx context.Context, update func([]string, error))
+}
+
+// StaticResolver is a Resolver for a fixed list of base URLs.
+type StaticResolver []string
+
+var _ Resolver = StaticResolver(nil)
+
+// Watch reports the list once, then waits for the context to be canceled.
+func (r StaticResolver) Watch(ctx context.Context, update func([]string, error)) {
+	if len(r) == 0 {
+		update(nil, errors.New("no endpoints configured"))
+	} else {
+		urls := make([]string, len(r))
+		copy(urls, r)
+		update(urls, nil)
+	}
+	<-ctx.Done()
+}
+
+// A DNSResolver discovers replicas using DNS A and AAAA records. Every address
+// for Host becomes a base URL with the configured Scheme and Port.
+type DNSResolver struct {
+	Host string
+	Port int
+	// Scheme is the URL scheme for the discovered replicas. If empty,
+	// DNSResolver uses "https".
+	Scheme string
+	// Interval is how often to repeat the lookup. If zero, DNSResolver uses 30
+	// seconds.
+	Interval time.Duration
+	// Resolver is the DNS resolver to use. If nil, DNSResolver uses
+	// net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+var _ Resolver = (*DNSResolver)(nil)
+
+// Watch looks up the host's addresses every Interval, reporting changes.
+func (r *DNSResolver) Watch(ctx context.Context, update func([]string, error)) {
+	poll(ctx, r.Interval, defaultDNSInterval, update, func(ctx context.Context) ([]string, error) {
+		addrs, err := dnsResolver(r.Resolver).LookupHost(ctx, r.Host)
+		if err != nil {
+			return nil, fmt.Errorf("can't look up host: %w", err)
+		}
+		urls := make([]string, len(addrs))
+		for i, addr := range addrs {
+			urls[i] = scheme(r.Scheme) + "://" + net.JoinHostPort(addr, strconv.Itoa(r.Port))
+		}
+		return urls, nil
+	})
+}
+
+// A DNSSRVResolver discovers replicas using DNS SRV records, as described in
+// RFC 2782. For example, a DNSSRVResolver with Service "grpc", Proto "tcp",
+// and Name "ping.example.com" looks up _grpc._tcp.ping.example.com.
+//
+// Only the records with the lowest priority are used, and their weights are
+// ignored: Balancers apply their own policies to the returned replicas.
+type DNSSRVResolver struct {
+	Service string
+	Proto   string
+	Name    string
+	// Scheme is the URL scheme for the discovered replicas. If empty,
+	// DNSSRVResolver uses "https".
+	Scheme string
+	// Interval is how often to repeat the lookup. If zero, DNSSRVResolver uses
+	// 30 seconds.
+	Interval time.Duration
+	// Resolver is the DNS resolver to use. If nil, DNSSRVResolver uses
+	// net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+var _ Resolver = (*DNSSRVResolver)(nil)
+
+// Watch looks up the SRV records every Interval, reporting changes.
+func (r *DNSSRVResolver) Watch(ctx context.Context, update func([]string, error)) {
+	poll(ctx, r.Interval, defaultDNSInterval, update, func(ctx context.Context) ([]string, error) {
+		_, records, err := dnsResolver(r.Resolver).LookupSRV(ctx, r.Service, r.Proto, r.Name)
+		if err != nil {
+			return nil, fmt.Errorf("can't look up SRV records: %w", err)
+		}
+		return srvURLs(records, r.Scheme)
+	})
+}
+
+// A FileResolver reads replicas from a file, which is convenient for local
+// development. The file must contain an object with an "endpoints" key
+// listing base URLs:
+//
+//   {"endpoints": ["http://localhost:8080", "http://localhost:8081"]}
+//
+// By default, FileResolver parses JSON. To use YAML or another format, set
+// Unmarshal (for example, to yaml.Unmarshal from gopkg.in/yaml.v3).
+type FileResolver struct {
+	Path string
+	// Interval is how often to check the file for changes. If zero,
+	// FileResolver uses one second.
+	Interval time.Duration
+	// Unmarshal decodes the file. If nil, FileResolver uses json.Unmarshal.
+	Unmarshal func([]byte, interface{}) error
+}
+
+var _ Resolver = (*FileResolver)(nil)
+
+// Watch reads the file every Interval, reporting changes.
+func (r *FileResolver) Watch(ctx context.Context, update func([]string, error)) {
+	poll(ctx, r.Interval, defaultFileInterval, update, func(ctx context.Context) ([]string, error) {
+		contents, err := os.ReadFile(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("can't read endpoints file: %w", err)
+		}
+		unmarshal := r.Unmarshal
+		if unmarshal == nil {
+			unmarshal = json.Unmarshal
+		}
+		var file struct {
+			Endpoints []string `json:"endpoints" yaml:"endpoints"`
+		}
+		if err := unmarshal(contents, &file); err != nil {
+			return nil, fmt.Errorf("can't parse endpoints file: %w", err)
+		}
+		return file.Endpoints, nil
+	})
+}
+
+// poll calls resolve immediately and then every interval until the context
+// is canceled, reporting errors and changed sets of URLs.
+func poll(
+	ctx context.Context,
+	interval, defaultInterval time.Duration,
+	update func([]string, error),
+	resolve func(context.Context) ([]string, error),
+) {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last []string
+	reported := false
+	for {
+		urls, err := resolve(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			update(nil, err)
+			reported = false // report the next success, even if it's unchanged
+		} else if sorted := sortedCopy(urls); !reported || !equalStrings(sorted, last) {
+			update(sorted, nil)
+			last, reported = sorted, true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sortedCopy(ss []string) []string {
+	sorted := make([]string, len(ss))
+	copy(sorted, ss)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func dnsResolver(r *net.Resolver) *net.Resolver {
+	if r == nil {
+		return net.DefaultResolver
+	}
+	return r
+}
+
+func scheme(s string) string {
+	if s == "" {
+		return "https"
+	}
+	return s
+}
+
+// srvURLs converts the SRV records with the lowest priority into base URLs.
+func srvURLs(records []*net.SRV, urlScheme string) ([]string, error) {
+	if len(records) == 0 {
+		return nil, errors.New("no SRV records found")
+	}
+	lowest := records[0].Priority
+	for _, rec := range records[1:] {
+		if rec.Priority < lowest {
+			lowest = rec.Priority
+		}
+	}
+	var urls []string
+	for _, rec := range records {
+		if rec.Priority != lowest {
+			continue
+		}
+		host := strings.TrimSuffix(rec.Target, ".")
+		port := strconv.Itoa(int(rec.Port))
+		urls = append(urls, scheme(urlScheme)+"://"+net.JoinHostPort(host, port))
+	}
+	return urls, nil
+}
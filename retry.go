@@ -0,0 +1,213 @@
+package rerpc
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Defaults for the zero values of RetryPolicy's fields.
+const (
+	defaultInitialBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff        = time.Second
+	defaultBackoffMultiplier = 2.0
+)
+
+// A RetryPolicy configures client-side retries of failed unary calls. Retries
+// happen inside the interceptor chain, so interceptors see a single call no
+// matter how many attempts it takes. Streaming calls are never retried.
+//
+// Clients wait between attempts, using exponential backoff with full jitter:
+// before each retry, they wait a random duration between zero and the current
+// backoff, and then multiply the backoff for the next retry. Retries never
+// extend the call's deadline: if the context would expire before the next
+// attempt, the client returns the most recent error immediately. Each attempt
+// sends the remaining time in the Grpc-Timeout header.
+//
+// gRPC servers may override the backoff with the grpc-retry-pushback-ms
+// trailer. A non-negative pushback delays the next attempt by that many
+// milliseconds (and resets the backoff), and a negative or malformed pushback
+// stops the client from retrying.
+//
+// RetryPolicies are valid CallOptions. By default, clients don't retry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the original
+	// call. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the backoff before the first retry. If zero, clients
+	// use 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff between attempts. If zero, clients use one
+	// second.
+	MaxBackoff time.Duration
+	// BackoffMultiplier scales the backoff after each retry. If less than one,
+	// clients use two.
+	BackoffMultiplier float64
+	// RetryableCodes lists the error codes worth retrying. If empty, clients
+	// only retry CodeUnavailable. Retried calls may reach the server more than
+	// once, so take care when adding codes like CodeDeadlineExceeded or
+	// CodeAborted.
+	RetryableCodes []Code
+	// Methods limits the policy to the listed fully-qualified protobuf methods
+	// (e.g., "acme.foo.v1.FooService.Bar") or services (e.g.,
+	// "acme.foo.v1.FooService"). A policy for a method takes precedence over a
+	// policy for its service, which in turn takes precedence over a policy
+	// without any Methods.
+	Methods []string
+}
+
+func (p *RetryPolicy) applyToCall(cfg *callCfg) {
+	if len(p.Methods) == 0 {
+		cfg.RetryPolicy = p
+		return
+	}
+	// Each call builds a fresh configuration, so writing to this map doesn't
+	// affect other calls.
+	if cfg.MethodRetryPolicies == nil {
+		cfg.MethodRetryPolicies = make(map[string]*RetryPolicy, len(p.Methods))
+	}
+	for _, name := range p.Methods {
+		cfg.MethodRetryPolicies[name] = p
+	}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) initialBackoff() time.Duration {
+	if p.InitialBackoff <= 0 {
+		return defaultInitialBackoff
+	}
+	return p.InitialBackoff
+}
+
+// nextBackoff applies the multiplier to the current backoff, respecting the
+// maximum.
+func (p *RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	multiplier := p.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+	next := time.Duration(float64(backoff) * multiplier)
+	if next > max || next < 0 { // guard against overflow
+		return max
+	}
+	return next
+}
+
+func (p *RetryPolicy) retryable(code Code) bool {
+	if len(p.RetryableCodes) == 0 {
+		return code == CodeUnavailable
+	}
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicy finds the policy for a method, preferring method-specific
+// policies to service-wide policies to the default. It returns nil if the
+// call shouldn't be retried.
+func (cfg *callCfg) retryPolicy(methodFQN, serviceFQN string) *RetryPolicy {
+	if p, ok := cfg.MethodRetryPolicies[methodFQN]; ok {
+		return p
+	}
+	if p, ok := cfg.MethodRetryPolicies[serviceFQN]; ok {
+		return p
+	}
+	return cfg.RetryPolicy
+}
+
+// pushback is the server's instruction about retrying a failed call, sent in
+// the grpc-retry-pushback-ms trailer.
+type pushback struct {
+	delay time.Duration // negative means "don't retry"
+	ok    bool          // false if the server didn't send a pushback
+}
+
+func parsePushback(trailer http.Header) pushback {
+	value := trailer.Get("Grpc-Retry-Pushback-Ms")
+	if value == "" {
+		return pushback{}
+	}
+	ms, err := strconv.ParseInt(value, 10 /* base */, 32 /* bitsize */)
+	if err != nil || ms < 0 {
+		return pushback{delay: -1, ok: true}
+	}
+	return pushback{delay: time.Duration(ms) * time.Millisecond, ok: true}
+}
+
+// responsePushback looks for a pushback in the response's trailers or, for
+// trailers-only responses, in its headers.
+func responsePushback(response *http.Response) pushback {
+	if pb := parsePushback(response.Header); pb.ok {
+		return pb
+	}
+	return parsePushback(response.Trailer)
+}
+
+// callWithRetries makes a unary call, retrying according to the configured
+// RetryPolicy.
+func (c *Client) callWithRetries(ctx context.Context, req proto.Message, cfg *callCfg) (proto.Message, *Error) {
+	policy := cfg.retryPolicy(c.methodFQN, c.serviceFQN)
+	attempts := policy.maxAttempts()
+	var backoff time.Duration
+	if attempts > 1 {
+		backoff = policy.initialBackoff()
+	}
+	for attempt := 1; ; attempt++ {
+		res, pb, err := c.attempt(ctx, req, cfg)
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= attempts || !policy.retryable(err.Code()) || ctx.Err() != nil {
+			return nil, err
+		}
+		var delay time.Duration
+		if pb.ok {
+			if pb.delay < 0 {
+				return nil, err
+			}
+			delay = pb.delay
+			backoff = policy.initialBackoff()
+		} else {
+			delay = time.Duration(rand.Int63n(int64(backoff) + 1))
+			backoff = policy.nextBackoff(backoff)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			// Waiting would exhaust the call's timeout budget.
+			return nil, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single unary call.
+func (c *Client) attempt(ctx context.Context, req proto.Message, cfg *callCfg) (proto.Message, pushback, *Error) {
+	if cfg.TwirpContentType != "" {
+		// Twirp doesn't have trailers, so it can't push back.
+		res, err := c.callTwirp(ctx, req, cfg)
+		return res, pushback{}, err
+	}
+	return c.call(ctx, req, cfg)
+}
@@ -0,0 +1,62 @@
+package rerpc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rerpc/rerpc/internal/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	var policy *RetryPolicy
+	assert.Equal(t, policy.maxAttempts(), 1, "nil policy attempts")
+
+	policy = &RetryPolicy{MaxAttempts: 5}
+	assert.Equal(t, policy.initialBackoff(), defaultInitialBackoff, "default initial backoff")
+	assert.Equal(t, policy.nextBackoff(100*time.Millisecond), 200*time.Millisecond, "default multiplier")
+	assert.Equal(t, policy.nextBackoff(800*time.Millisecond), defaultMaxBackoff, "default max backoff")
+	assert.True(t, policy.retryable(CodeUnavailable), "unavailable retryable by default")
+	assert.False(t, policy.retryable(CodeInternal), "internal not retryable by default")
+
+	policy = &RetryPolicy{
+		MaxBackoff:        time.Duration(1 << 62),
+		BackoffMultiplier: 4,
+		RetryableCodes:    []Code{CodeAborted},
+	}
+	assert.Equal(t, policy.nextBackoff(time.Duration(1<<61)), time.Duration(1<<62), "overflow clamped")
+	assert.True(t, policy.retryable(CodeAborted), "custom code retryable")
+	assert.False(t, policy.retryable(CodeUnavailable), "custom codes replace default")
+}
+
+func TestParsePushback(t *testing.T) {
+	pushbackHeader := func(value string) http.Header {
+		h := make(http.Header)
+		h.Set("Grpc-Retry-Pushback-Ms", value)
+		return h
+	}
+	assertPushback := func(h http.Header, delay time.Duration, ok bool, msg string) {
+		t.Helper()
+		pb := parsePushback(h)
+		assert.Equal(t, pb.delay, delay, msg+" delay")
+		assert.Equal(t, pb.ok, ok, msg+" ok")
+	}
+	assertPushback(http.Header{}, 0, false, "missing")
+	assertPushback(pushbackHeader("250"), 250*time.Millisecond, true, "valid")
+	assertPushback(pushbackHeader("-1"), -1, true, "negative")
+	assertPushback(pushbackHeader("soon"), -1, true, "malformed")
+}
+
+func TestRetryPolicyPrecedence(t *testing.T) {
+	const method, service = "acme.foo.v1.FooService.Bar", "acme.foo.v1.FooService"
+	fallback := &RetryPolicy{MaxAttempts: 2}
+	forService := &RetryPolicy{MaxAttempts: 3, Methods: []string{service}}
+	forMethod := &RetryPolicy{MaxAttempts: 4, Methods: []string{method}}
+	var cfg callCfg
+	for _, opt := range []CallOption{forMethod, forService, fallback} {
+		opt.applyToCall(&cfg)
+	}
+	assert.Equal(t, cfg.retryPolicy(method, service).MaxAttempts, 4, "method")
+	assert.Equal(t, cfg.retryPolicy(service+".Baz", service).MaxAttempts, 3, "service")
+	assert.Equal(t, cfg.retryPolicy("other.Service.Method", "other.Service").MaxAttempts, 2, "fallback")
+}
@@ -0,0 +1,342 @@
+package rerpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// StreamType describes whether the client, server, neither, or both is
+// streaming.
+type StreamType uint8
+
+const (
+	StreamTypeUnary         StreamType = 0b00                                // one request, one response
+	StreamTypeClient        StreamType = 0b01                                // many requests, one response
+	StreamTypeServer        StreamType = 0b10                                // one request, many responses
+	StreamTypeBidirectional StreamType = StreamTypeClient | StreamTypeServer // many requests, many responses
+)
+
+// streamingName describes a non-unary stream type in error messages.
+func streamingName(t StreamType) string {
+	switch t {
+	case StreamTypeClient:
+		return "client streaming"
+	case StreamTypeServer:
+		return "server streaming"
+	case StreamTypeBidirectional:
+		return "bidirectional streaming"
+	default:
+		return "unary"
+	}
+}
+
+// A Stream is a sequence of protobuf messages exchanged between a client and a
+// handler. It's the interface between the reRPC library and the streaming code
+// generated by the reRPC protoc plugin; most users won't ever need to deal
+// with it directly. Instead, they'll use the typed wrappers in the generated
+// code.
+//
+// Clients send requests and receive responses, while handlers receive
+// requests and send responses. Both halves of a stream must be closed.
+// Calling CloseSend with a nil error indicates that the sender has finished
+// successfully; on handlers, a non-nil error is sent to the client as a gRPC
+// status. Once the other party has closed their half of the stream, Receive
+// returns an error wrapping io.EOF.
+//
+// Streams aren't safe for concurrent use by multiple goroutines, but it's
+// safe to call Send and Receive concurrently.
+type Stream interface {
+	Context() context.Context
+
+	Send(proto.Message) error
+	CloseSend(error) error
+
+	Receive(proto.Message) error
+	CloseReceive() error
+}
+
+type serverStream struct {
+	ctx              context.Context
+	writer           http.ResponseWriter
+	reader           io.Reader
+	compressor       Compressor // for responses
+	compressMinBytes int
+	decompressor     Compressor // for requests
+	maxRequestBytes  int
+	maxDecompressed  int // for requests
+	maxResponseBytes int
+	hooks            *Hooks
+}
+
+var _ Stream = (*serverStream)(nil)
+
+func newServerStream(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r io.Reader,
+	spec *Specification,
+	cfg *handlerCfg,
+) *serverStream {
+	// Handlers have already validated the compression methods.
+	compressor, _ := cfg.Compressors.get(spec.ResponseCompression)
+	decompressor, _ := cfg.Compressors.get(spec.RequestCompression)
+	return &serverStream{
+		ctx:              ctx,
+		writer:           w,
+		reader:           r,
+		compressor:       compressor,
+		compressMinBytes: cfg.CompressMinBytes,
+		decompressor:     decompressor,
+		maxRequestBytes:  cfg.MaxRequestBytes,
+		maxDecompressed:  decompressedLimit(cfg.MaxRequestBytes, cfg.MaxDecompressedBytes),
+		maxResponseBytes: cfg.MaxResponseBytes,
+		hooks:            cfg.Hooks,
+	}
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) Receive(msg proto.Message) error {
+	if err := unmarshalLPM(ss.reader, msg, ss.decompressor, ss.maxRequestBytes, ss.maxDecompressed); err != nil {
+		if errors.Is(err, io.EOF) {
+			return err // client closed the stream
+		}
+		if errors.Is(err, errTooLarge) {
+			return wrap(CodeResourceExhausted, err)
+		}
+		return errorf(CodeInvalidArgument, "can't unmarshal protobuf body")
+	}
+	return nil
+}
+
+func (ss *serverStream) CloseReceive() error {
+	// Consume the remainder of the request body, so the underlying connection
+	// can be reused.
+	if _, err := io.Copy(ioutil.Discard, ss.reader); err != nil {
+		return errorf(CodeUnknown, "can't discard request body: %w", err)
+	}
+	return nil
+}
+
+func (ss *serverStream) Send(msg proto.Message) error {
+	if err := marshalLPM(ss.ctx, ss.writer, msg, ss.compressor, ss.compressMinBytes, ss.maxResponseBytes, ss.hooks); errors.Is(err, errTooLarge) {
+		return wrap(CodeResourceExhausted, err)
+	} else if err != nil {
+		return errorf(CodeUnknown, "can't send protobuf message: %w", err)
+	}
+	// Clients expect each message as soon as it's sent.
+	if f, ok := ss.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (ss *serverStream) CloseSend(err error) error {
+	// It's safe to write gRPC errors even after we've started writing the body.
+	writeErrorGRPC(ss.ctx, ss.writer, err, ss.hooks)
+	return nil
+}
+
+// clientStream sends requests over an HTTP request body and reads responses
+// from the HTTP response body. Because the request body is a pipe, the HTTP
+// request is sent in a separate goroutine; receiving blocks until the server
+// sends response headers.
+type clientStream struct {
+	ctx                     context.Context
+	doer                    Doer
+	url                     string
+	md                      CallMetadata
+	maxResBytes             int
+	maxDecompressedResBytes int
+	maxReqBytes             int
+	compressor              Compressor // for requests
+	compressMin             int
+	compressors             *compressors
+	hooks                   *Hooks
+
+	writer *io.PipeWriter
+
+	prepareOnce  sync.Once
+	reader       *io.PipeReader
+	cancel       context.CancelFunc
+	responseErr  *Error
+	response     *http.Response
+	ready        chan struct{}
+	decompressor Compressor // for responses
+}
+
+var _ Stream = (*clientStream)(nil)
+
+func newClientStream(ctx context.Context, doer Doer, url string, cfg *callCfg) (*clientStream, *Error) {
+	md, ok := CallMeta(ctx)
+	if !ok {
+		return nil, errorf(CodeInternal, "no call metadata available on context")
+	}
+	compressor, err := cfg.requestCompressor(md.Spec.RequestCompression)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	return &clientStream{
+		ctx:                     ctx,
+		doer:                    doer,
+		url:                     url,
+		md:                      md,
+		maxResBytes:             cfg.MaxResponseBytes,
+		maxDecompressedResBytes: decompressedLimit(cfg.MaxResponseBytes, cfg.MaxDecompressedBytes),
+		maxReqBytes:             cfg.MaxRequestBytes,
+		compressor:              compressor,
+		compressMin:             cfg.CompressMinBytes,
+		compressors:             cfg.Compressors,
+		hooks:                   cfg.Hooks,
+		writer:                  pw,
+		reader:                  pr,
+		ready:                   make(chan struct{}),
+	}, nil
+}
+
+func (cs *clientStream) Context() context.Context {
+	return cs.ctx
+}
+
+func (cs *clientStream) Send(msg proto.Message) error {
+	// Marshal before preparing the request, so a message that's too large to
+	// send doesn't open a stream to the server.
+	data := &bytes.Buffer{}
+	if err := marshalLPM(cs.ctx, data, msg, cs.compressor, cs.compressMin, cs.maxReqBytes, cs.hooks); errors.Is(err, errTooLarge) {
+		// The message wasn't sent, so the stream is still usable.
+		return wrap(CodeResourceExhausted, err)
+	} else if err != nil {
+		return errorf(CodeInvalidArgument, "can't marshal request as protobuf: %w", err)
+	}
+	cs.prepareOnce.Do(cs.prepareRequest)
+	if _, err := data.WriteTo(cs.writer); err != nil {
+		cs.hooks.onNetworkError(cs.ctx, fmt.Errorf("couldn't write length-prefixed message: %w", err))
+		// If the server (or the transport) closed the stream, the request body
+		// pipe is closed. The reason for the closure is in the response, so wait
+		// for it.
+		<-cs.ready
+		if cs.responseErr != nil {
+			return cs.responseErr
+		}
+		// The server closed the stream. Callers should call Receive to see the
+		// status the server sent.
+		return fmt.Errorf("server closed the stream: %w", io.EOF)
+	}
+	return nil
+}
+
+func (cs *clientStream) CloseSend(err error) error {
+	cs.prepareOnce.Do(cs.prepareRequest)
+	if err != nil {
+		return cs.writer.CloseWithError(err)
+	}
+	return cs.writer.Close()
+}
+
+func (cs *clientStream) Receive(msg proto.Message) error {
+	cs.prepareOnce.Do(cs.prepareRequest)
+	<-cs.ready
+	if cs.responseErr != nil {
+		return cs.responseErr
+	}
+	// Handling this error is a little complicated - read on.
+	unmarshalErr := unmarshalLPM(cs.response.Body, msg, cs.decompressor, cs.maxResBytes, cs.maxDecompressedResBytes)
+	if unmarshalErr == nil {
+		return nil
+	}
+	// The server is done sending messages, or something went wrong. To ensure
+	// that we've read the trailers, read the body to completion.
+	io.Copy(ioutil.Discard, cs.response.Body)
+	if serverErr := extractError(cs.response.Trailer); serverErr != nil {
+		// Server sent us an error. In this case, we don't care if the
+		// length-prefixed message was corrupted and unmarshalErr is non-nil.
+		return reconcileContextError(cs.ctx, serverErr, false /* local */)
+	}
+	if errors.Is(unmarshalErr, io.EOF) {
+		// Server closed its half of the stream successfully.
+		return unmarshalErr
+	}
+	if errors.Is(unmarshalErr, errTooLarge) {
+		return wrap(CodeResourceExhausted, unmarshalErr)
+	}
+	// Server thinks the stream was successful, so unmarshalErr is real. It may
+	// be a read error caused by the context, though.
+	rerr := errorf(CodeUnknown, "server returned invalid protobuf: %w", unmarshalErr)
+	return reconcileContextError(cs.ctx, rerr, true /* local */)
+}
+
+func (cs *clientStream) CloseReceive() error {
+	cs.prepareOnce.Do(cs.prepareRequest)
+	// Canceling the request's context releases any resources associated with
+	// it and, if we haven't read the whole response, tells the server that
+	// we've stopped listening.
+	if cs.cancel != nil {
+		cs.cancel()
+	}
+	<-cs.ready
+	if cs.response == nil {
+		return nil
+	}
+	if err := cs.response.Body.Close(); err != nil {
+		return wrap(CodeUnknown, err)
+	}
+	return nil
+}
+
+func (cs *clientStream) prepareRequest() {
+	ctx, cancel := context.WithCancel(cs.ctx)
+	cs.cancel = cancel
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, cs.url, cs.reader)
+	if err != nil {
+		cs.responseErr = errorf(CodeInternal, "can't create HTTP request: %w", err)
+		cs.reader.CloseWithError(cs.responseErr)
+		close(cs.ready)
+		return
+	}
+	request.Header = cs.md.req.raw
+	go cs.makeRequest(request)
+}
+
+func (cs *clientStream) makeRequest(request *http.Request) {
+	defer close(cs.ready)
+	response, err := cs.doer.Do(request)
+	if err != nil {
+		cs.responseErr = wrapDoerError(cs.ctx, err)
+		cs.reader.CloseWithError(cs.responseErr)
+		return
+	}
+	*cs.md.res = NewImmutableHeader(response.Header)
+	if cs.md.Spec.Type&StreamTypeClient != 0 && response.ProtoMajor < 2 {
+		cs.responseErr = errorf(
+			CodeUnimplemented,
+			"%s requires HTTP/2, but the server responded with %s",
+			streamingName(cs.md.Spec.Type),
+			response.Proto,
+		)
+		cs.reader.CloseWithError(cs.responseErr)
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		return
+	}
+	decompressor, rerr := validateResponse(response, cs.compressors)
+	if rerr != nil {
+		cs.responseErr = reconcileContextError(cs.ctx, rerr, false /* local */)
+		cs.reader.CloseWithError(cs.responseErr)
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		return
+	}
+	cs.response = response
+	cs.decompressor = decompressor
+}
@@ -5,14 +5,21 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	maxHours        = math.MaxInt64 / int64(time.Hour) // how many hours fit into a time.Duration?
-	maxTimeoutChars = 8                                // from gRPC protocol
+	maxHours        = math.MaxInt64 / int64(time.Hour)        // how many hours fit into a time.Duration?
+	maxMilliseconds = math.MaxInt64 / int64(time.Millisecond) // how many milliseconds fit into a time.Duration?
+	maxTimeoutChars = 8                                       // from gRPC protocol
 )
 
+// twirpTimeoutHeader carries timeouts on Twirp requests, since the Twirp
+// protocol doesn't have its own. Its value is a whole number of milliseconds,
+// which is easier to set from browsers and scripts than Grpc-Timeout.
+const twirpTimeoutHeader = "Rerpc-Timeout-Ms"
+
 var (
 	errNoTimeout = errors.New("no timeout")
 	timeoutUnits = []struct {
@@ -58,6 +65,35 @@ func parseTimeout(timeout string) (time.Duration, error) {
 	return time.Duration(num) * unit, nil
 }
 
+// parseTwirpTimeout parses the value of the Rerpc-Timeout-Ms header.
+func parseTwirpTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, errNoTimeout
+	}
+	num, err := strconv.ParseInt(timeout, 10 /* base */, 64 /* bitsize */)
+	if err != nil || num < 0 || strings.HasPrefix(timeout, "+") {
+		return 0, fmt.Errorf("invalid %s %q: must be a whole number of milliseconds", twirpTimeoutHeader, timeout)
+	}
+	if num > maxMilliseconds {
+		// As with gRPC, treat unrepresentable timeouts as unbounded.
+		return 0, errNoTimeout
+	}
+	return time.Duration(num) * time.Millisecond, nil
+}
+
+// encodeTwirpTimeout formats a Rerpc-Timeout-Ms header, rounding up so that
+// short timeouts don't become zero.
+func encodeTwirpTimeout(t time.Duration) string {
+	if t <= 0 {
+		return "0"
+	}
+	ms := t / time.Millisecond
+	if t%time.Millisecond != 0 {
+		ms++
+	}
+	return strconv.FormatInt(int64(ms), 10 /* base */)
+}
+
 func encodeTimeout(t time.Duration) (string, error) {
 	if t <= 0 {
 		return "0n", nil
@@ -55,3 +55,33 @@ func TestEncodeTimeoutQuick(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestParseTwirpTimeout(t *testing.T) {
+	_, err := parseTwirpTimeout("")
+	assert.True(t, err == errNoTimeout, "expect errNoTimeout for empty string")
+	for _, invalid := range []string{"foo", "1.5", "-1", "+1", "1S"} {
+		_, err = parseTwirpTimeout(invalid)
+		assert.NotNil(t, err, invalid)
+		assert.False(t, err == errNoTimeout, invalid)
+	}
+	_, err = parseTwirpTimeout("9223372036855") // overflows time.Duration
+	assert.True(t, err == errNoTimeout, "effectively unbounded")
+
+	d, err := parseTwirpTimeout("1500")
+	assert.Nil(t, err, "1500")
+	assert.Equal(t, d, 1500*time.Millisecond, "1500")
+	d, err = parseTwirpTimeout("0")
+	assert.Nil(t, err, "0")
+	assert.Zero(t, d, "0")
+}
+
+func TestEncodeTwirpTimeout(t *testing.T) {
+	assert.Equal(t, encodeTwirpTimeout(1500*time.Millisecond), "1500", "1.5s")
+	assert.Equal(t, encodeTwirpTimeout(time.Microsecond), "1", "rounds up")
+	assert.Equal(t, encodeTwirpTimeout(-time.Second), "0", "negative duration")
+	d, err := parseTwirpTimeout(encodeTwirpTimeout(time.Hour))
+	assert.Nil(t, err, "round trip")
+	assert.Equal(t, d, time.Hour, "round trip")
+	_, err = parseTwirpTimeout(encodeTwirpTimeout(time.Duration(math.MaxInt64)))
+	assert.True(t, err == errNoTimeout, "max duration is effectively unbounded")
+}